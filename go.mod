@@ -4,9 +4,10 @@ go 1.18
 
 require (
 	github.com/go-kit/kit v0.12.0
-	github.com/gregjones/httpcache v0.0.0-20171119193500-2bcd89a1743f
 	github.com/kr/pretty v0.3.0
 	github.com/mitchellh/mapstructure v1.5.0
+	go.etcd.io/bbolt v1.3.7
+	golang.org/x/time v0.0.0-20210723032227-1f47c861a9ac
 	gonum.org/v1/gonum v0.11.0
 	googlemaps.github.io/maps v1.3.2
 )
@@ -18,5 +19,5 @@ require (
 	github.com/kr/text v0.2.0 // indirect
 	github.com/rogpeppe/go-internal v1.6.1 // indirect
 	go.opencensus.io v0.23.0 // indirect
-	golang.org/x/time v0.0.0-20210723032227-1f47c861a9ac // indirect
+	golang.org/x/sys v0.4.0 // indirect
 )
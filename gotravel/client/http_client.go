@@ -10,6 +10,7 @@ import (
 
 	"github.com/kr/pretty"
 	"github.com/radekwlsk/go-travel/gotravel/gotravelservice"
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/tenant"
 	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/trip"
 	"github.com/radekwlsk/go-travel/gotravel/gotraveltransport"
 )
@@ -18,7 +19,8 @@ func main() {
 	var (
 		httpAddr = flag.String("http-addr", "127.0.0.1:8080",
 			"HTTP address of gotravelcli in host:port format")
-		method = flag.String("method", "tripplan", "tripplan, ")
+		method = flag.String("method", "tripplan", "tripplan, estimate, usage")
+		token  = flag.String("token", "", "tenant token sent as bearer token")
 	)
 	flag.Parse()
 
@@ -45,11 +47,36 @@ func main() {
 		}
 		var tc trip.Configuration
 		json.Unmarshal(raw, &tc)
-		ctx := context.Background()
+		ctx := tenant.ContextWithToken(context.Background(), *token)
 		tripPlan(ctx, svc, tc)
 
+	case "estimate":
+		raw, err := ioutil.ReadFile(flag.Args()[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error reading JSON file: %v\n", err)
+			os.Exit(1)
+		}
+		var tc trip.Configuration
+		json.Unmarshal(raw, &tc)
+		ctx := tenant.ContextWithToken(context.Background(), *token)
+		e, err := svc.Estimate(ctx, tc)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stdout, "%s", pretty.Sprint(e))
+
+	case "usage":
+		ctx := tenant.ContextWithToken(context.Background(), *token)
+		r, err := svc.Usage(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stdout, "%s", pretty.Sprint(r))
+
 	default:
-		fmt.Fprintf(os.Stderr, "error: invalid method %q\n", method)
+		fmt.Fprintf(os.Stderr, "error: invalid method %q\n", *method)
 		os.Exit(1)
 	}
 
@@ -7,10 +7,13 @@ import (
 	"github.com/go-kit/kit/log"
 	"github.com/radekwlsk/go-travel/gotravel/gotravelservice"
 	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/trip"
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/usage"
 )
 
 type Endpoints struct {
 	TripPlanEndpoint endpoint.Endpoint
+	EstimateEndpoint endpoint.Endpoint
+	UsageEndpoint    endpoint.Endpoint
 }
 
 func New(s gotravelservice.Service, logger log.Logger) Endpoints {
@@ -19,8 +22,20 @@ func New(s gotravelservice.Service, logger log.Logger) Endpoints {
 		tripPlanEndpoint = NewTripPlanEndpoint(s)
 		tripPlanEndpoint = NewLoggingMiddleware(log.With(logger, "layer", "endpoint"))(tripPlanEndpoint)
 	}
+	var estimateEndpoint endpoint.Endpoint
+	{
+		estimateEndpoint = NewEstimateEndpoint(s)
+		estimateEndpoint = NewLoggingMiddleware(log.With(logger, "layer", "endpoint"))(estimateEndpoint)
+	}
+	var usageEndpoint endpoint.Endpoint
+	{
+		usageEndpoint = NewUsageEndpoint(s)
+		usageEndpoint = NewLoggingMiddleware(log.With(logger, "layer", "endpoint"))(usageEndpoint)
+	}
 	return Endpoints{
 		TripPlanEndpoint: tripPlanEndpoint,
+		EstimateEndpoint: estimateEndpoint,
+		UsageEndpoint:    usageEndpoint,
 	}
 }
 
@@ -33,6 +48,24 @@ func (e Endpoints) TripPlan(ctx context.Context, tc trip.Configuration) (trip.Tr
 	return resp.Trip, resp.Err
 }
 
+func (e Endpoints) Estimate(ctx context.Context, tc trip.Configuration) (gotravelservice.Estimate, error) {
+	response, err := e.EstimateEndpoint(ctx, EstimateRequest{TripConfiguration: tc})
+	if err != nil {
+		return gotravelservice.Estimate{}, err
+	}
+	resp := response.(EstimateResponse)
+	return resp.Estimate, resp.Err
+}
+
+func (e Endpoints) Usage(ctx context.Context) (usage.Report, error) {
+	response, err := e.UsageEndpoint(ctx, UsageRequest{})
+	if err != nil {
+		return usage.Report{}, err
+	}
+	resp := response.(UsageResponse)
+	return resp.Report, resp.Err
+}
+
 func NewTripPlanEndpoint(s gotravelservice.Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(TripPlanRequest)
@@ -51,3 +84,38 @@ type TripPlanResponse struct {
 }
 
 func (r TripPlanResponse) Error() error { return r.Err }
+
+func NewEstimateEndpoint(s gotravelservice.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(EstimateRequest)
+		resp, e := s.Estimate(ctx, req.TripConfiguration)
+		return EstimateResponse{Estimate: resp, Err: e}, nil
+	}
+}
+
+type EstimateRequest struct {
+	TripConfiguration trip.Configuration
+}
+
+type EstimateResponse struct {
+	gotravelservice.Estimate
+	Err error `json:"err,omitempty"`
+}
+
+func (r EstimateResponse) Error() error { return r.Err }
+
+func NewUsageEndpoint(s gotravelservice.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		resp, e := s.Usage(ctx)
+		return UsageResponse{Report: resp, Err: e}, nil
+	}
+}
+
+type UsageRequest struct{}
+
+type UsageResponse struct {
+	usage.Report
+	Err error `json:"err,omitempty"`
+}
+
+func (r UsageResponse) Error() error { return r.Err }
@@ -0,0 +1,67 @@
+package gotravelservice
+
+import (
+	"context"
+
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/tenant"
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/trip"
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/usage"
+)
+
+var ErrUnauthorized = tenant.ErrUnauthorized
+
+// NewAuthMiddleware returns a service middleware that authenticates tenants
+// by token from request context and replaces API key of the request with
+// tenant's one. Requests of unknown tenants can use their own API key only
+// if allowClientKeys is set.
+func NewAuthMiddleware(tenants *tenant.Registry, allowClientKeys bool) Middleware {
+	return func(next Service) Service {
+		return authMiddleware{tenants, allowClientKeys, next}
+	}
+}
+
+type authMiddleware struct {
+	tenants         *tenant.Registry
+	allowClientKeys bool
+	next            Service
+}
+
+func (mw authMiddleware) TripPlan(ctx context.Context, tc trip.Configuration) (trip.Trip, error) {
+	ctx, err := mw.authenticate(ctx, &tc)
+	if err != nil {
+		return trip.Trip{}, err
+	}
+	return mw.next.TripPlan(ctx, tc)
+}
+
+func (mw authMiddleware) Estimate(ctx context.Context, tc trip.Configuration) (Estimate, error) {
+	ctx, err := mw.authenticate(ctx, &tc)
+	if err != nil {
+		return Estimate{}, err
+	}
+	return mw.next.Estimate(ctx, tc)
+}
+
+// Usage of tenants is reported to authenticated requests only.
+func (mw authMiddleware) Usage(ctx context.Context) (usage.Report, error) {
+	t, ok := mw.tenants.Lookup(tenant.TokenFromContext(ctx))
+	if !ok {
+		return usage.Report{}, ErrUnauthorized
+	}
+	return mw.next.Usage(tenant.NewContext(ctx, t))
+}
+
+func (mw authMiddleware) authenticate(ctx context.Context, tc *trip.Configuration) (context.Context, error) {
+	token := tenant.TokenFromContext(ctx)
+	t, ok := mw.tenants.Lookup(token)
+	if !ok {
+		if token != "" || !mw.allowClientKeys {
+			return ctx, ErrUnauthorized
+		}
+		return ctx, nil
+	}
+	if t.APIKey != "" || !t.AllowClientKey {
+		tc.APIKey = t.APIKey
+	}
+	return tenant.NewContext(ctx, t), nil
+}
@@ -0,0 +1,51 @@
+package gotravelservice
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/provider/record"
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/tenant"
+)
+
+func TestNewLogsRejectedRequests(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tenants.json")
+	if err := os.WriteFile(path, []byte(`[{"name": "alpha", "token": "token-of-alpha-tenant"}]`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tenants, err := tenant.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	s := New(log.NewLogfmtLogger(&buf), Config{
+		Providers:      record.NewReplayFactory(replayDir),
+		AllowPastTrips: true,
+		Tenants:        tenants,
+	})
+
+	ctx := tenant.ContextWithToken(context.Background(), "token-of-someone-else")
+	if _, err := s.Estimate(ctx, replayConfiguration()); err != ErrUnauthorized {
+		t.Fatalf("Estimate error = %v, want ErrUnauthorized", err)
+	}
+	if line := buf.String(); !strings.Contains(line, "method=Estimate") || !strings.Contains(line, "tenant=anonymous") ||
+		!strings.Contains(line, "valid tenant token") {
+		t.Errorf("rejected request not logged, got %q", line)
+	}
+
+	buf.Reset()
+	ctx = tenant.ContextWithToken(context.Background(), "token-of-alpha-tenant")
+	if _, err := s.Usage(ctx); err != nil {
+		t.Fatalf("Usage error = %v", err)
+	}
+	if line := buf.String(); !strings.Contains(line, "method=Usage") || !strings.Contains(line, "tenant=alpha") {
+		t.Errorf("authenticated request not logged with tenant, got %q", line)
+	}
+}
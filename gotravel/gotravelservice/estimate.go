@@ -0,0 +1,94 @@
+package gotravelservice
+
+import (
+	"context"
+	"time"
+
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/planner"
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/provider"
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/provider/google"
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/trip"
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/usage"
+)
+
+// Estimate is predicted provider usage of planning a trip, assuming that
+// nothing is served from cache.
+type Estimate struct {
+	Usage usage.Usage `json:"usage"`
+	// SampledTimes are departure times travel matrices are fetched for.
+	SampledTimes []time.Time `json:"sampledTimes"`
+	Warnings     []string    `json:"warnings,omitempty"`
+}
+
+// Estimate validates trip configuration and predicts Google Maps calls
+// needed to plan it without making any calls, so no API key is needed.
+// Trip start moved to the earliest opening of places can make the actual
+// usage lower.
+func (s *service) Estimate(ctx context.Context, tc trip.Configuration) (e Estimate, err error) {
+	ts, te, err := s.validate(&tc)
+	if err != nil {
+		return Estimate{}, err
+	}
+	windows, err := dailyWindows(tc, ts, te)
+	if err != nil {
+		return Estimate{}, err
+	}
+	if windows == nil {
+		windows = []trip.Window{{Start: ts, End: te}}
+	}
+
+	counter := &usage.Counter{}
+	billedPlaces := s.backends.Places == google.Backend
+	var stay time.Duration
+	for _, place := range tc.PlacesConfiguration {
+		placeID, err := place.Description.(trip.Description).Resolve(ctx, dryResolver{counter, billedPlaces})
+		if err != nil {
+			return Estimate{}, err
+		}
+		if placeID != "" && billedPlaces {
+			counter.Billable(usage.Details, 1)
+		}
+		if place.StayDuration > 0 {
+			stay += time.Duration(place.StayDuration) * time.Minute
+		}
+	}
+
+	e.SampledTimes = planner.SampleWindows(windows)
+	if s.backends.MatrixIn(provider.TravelMode(tc.TravelMode)) == google.Backend {
+		requests, elements := planner.MatrixSize(len(tc.PlacesConfiguration), google.Limits)
+		counter.Billable(usage.Matrix, requests*len(e.SampledTimes))
+		counter.Billable(usage.MatrixElements, elements*len(e.SampledTimes))
+	}
+	e.Usage = counter.Usage()
+
+	var active time.Duration
+	for _, w := range windows {
+		active += w.End.Sub(w.Start)
+	}
+	if stay > active {
+		e.Warnings = append(e.Warnings, "stay durations of all places exceed trip duration, "+
+			"places with lower priority will be left out")
+	}
+	return e, nil
+}
+
+// dryResolver counts place resolution calls instead of making them, if they
+// are billed, and resolves every place to its query.
+type dryResolver struct {
+	counter *usage.Counter
+	billed  bool
+}
+
+func (r dryResolver) PlaceID(_ context.Context, query string) (string, error) {
+	if r.billed {
+		r.counter.Billable(usage.Autocomplete, 1)
+	}
+	return query, nil
+}
+
+func (r dryResolver) PlaceIDAt(_ context.Context, ll provider.LatLng) (string, error) {
+	if r.billed {
+		r.counter.Billable(usage.Geocoding, 1)
+	}
+	return ll.String(), nil
+}
@@ -0,0 +1,105 @@
+package gotravelservice
+
+import (
+	"context"
+	"testing"
+
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/provider"
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/provider/google"
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/provider/record"
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/usage"
+)
+
+func TestEstimateCountsGoogleCallsOnly(t *testing.T) {
+	googleOnly := provider.Backends{Places: google.Backend, Matrix: google.Backend}
+	withOSRM := googleOnly
+	withOSRM.Serve(provider.TravelModeWalking, "osrm http://localhost:5000")
+	withGTFS := googleOnly
+	withGTFS.Serve(provider.TravelModeTransit, "gtfs feed")
+
+	// replayConfiguration has 4 places resolved by address and 5 sampled
+	// times in its 8 hours long window
+	tests := []struct {
+		name     string
+		backends provider.Backends
+		want     usage.Usage
+	}{
+		{
+			name:     "google",
+			backends: googleOnly,
+			want: usage.Usage{
+				Autocomplete:   usage.Count{Billable: 4},
+				Details:        usage.Count{Billable: 4},
+				Matrix:         usage.Count{Billable: 5},
+				MatrixElements: usage.Count{Billable: 80},
+			},
+		},
+		{
+			name:     "nominatim places",
+			backends: provider.Backends{Places: "nominatim http://localhost:8080", Matrix: google.Backend},
+			want: usage.Usage{
+				Matrix:         usage.Count{Billable: 5},
+				MatrixElements: usage.Count{Billable: 80},
+			},
+		},
+		{
+			name:     "osrm walking",
+			backends: withOSRM,
+			want: usage.Usage{
+				Autocomplete: usage.Count{Billable: 4},
+				Details:      usage.Count{Billable: 4},
+			},
+		},
+		{
+			name:     "gtfs transit only",
+			backends: withGTFS,
+			want: usage.Usage{
+				Autocomplete:   usage.Count{Billable: 4},
+				Details:        usage.Count{Billable: 4},
+				Matrix:         usage.Count{Billable: 5},
+				MatrixElements: usage.Count{Billable: 80},
+			},
+		},
+		{
+			name: "unknown backends",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewService(Config{Backends: tt.backends, AllowPastTrips: true})
+			e, err := s.Estimate(context.Background(), replayConfiguration())
+			if err != nil {
+				t.Fatalf("Estimate: %v", err)
+			}
+			if e.Usage != tt.want {
+				t.Errorf("Estimate usage = %+v, want %+v", e.Usage, tt.want)
+			}
+		})
+	}
+}
+
+func TestEstimateMatchesTripPlanUsage(t *testing.T) {
+	s := NewService(Config{
+		Providers:      usage.NewFactory(record.NewReplayFactory(replayDir)),
+		Backends:       provider.Backends{Places: google.Backend, Matrix: google.Backend},
+		AllowPastTrips: true,
+	})
+	e, err := s.Estimate(context.Background(), replayConfiguration())
+	if err != nil {
+		t.Fatalf("Estimate: %v", err)
+	}
+	tr, err := s.TripPlan(context.Background(), replayConfiguration())
+	if err != nil {
+		t.Fatalf("TripPlan: %v", err)
+	}
+
+	got, want := tr.Usage, e.Usage
+	if got.Autocomplete != want.Autocomplete || got.Details != want.Details {
+		t.Errorf("TripPlan place usage %+v differs from estimated %+v", got, want)
+	}
+	// trip start moved to the earliest opening samples fewer times
+	if got.Matrix.Billable == 0 || got.Matrix.Billable > want.Matrix.Billable ||
+		got.MatrixElements.Billable > want.MatrixElements.Billable {
+		t.Errorf("TripPlan matrix usage %+v exceeds estimated %+v", got, want)
+	}
+}
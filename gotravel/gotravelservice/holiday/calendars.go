@@ -0,0 +1,165 @@
+package holiday
+
+import "time"
+
+// Calendars are built-in calendars of nationwide public holidays, regional
+// holidays and substitute days of holidays falling on weekends are not
+// included.
+var Calendars = []*Calendar{
+	{
+		Code:  "PL",
+		Names: []string{"Poland", "Polska"},
+		Rules: []Rule{
+			Fixed("New Year's Day", time.January, 1),
+			Fixed("Epiphany", time.January, 6).Since(2011),
+			Easter("Easter Sunday", 0),
+			Easter("Easter Monday", 1),
+			Fixed("Labour Day", time.May, 1),
+			Fixed("Constitution Day", time.May, 3),
+			Easter("Pentecost", 49),
+			Easter("Corpus Christi", 60),
+			Fixed("Assumption Day", time.August, 15),
+			Fixed("All Saints' Day", time.November, 1),
+			Fixed("Independence Day", time.November, 11),
+			Fixed("Christmas Eve", time.December, 24).Since(2025),
+			Fixed("Christmas Day", time.December, 25),
+			Fixed("Second Day of Christmas", time.December, 26),
+		},
+	},
+	{
+		Code:  "DE",
+		Names: []string{"Germany", "Deutschland"},
+		Rules: []Rule{
+			Fixed("New Year's Day", time.January, 1),
+			Easter("Good Friday", -2),
+			Easter("Easter Monday", 1),
+			Fixed("Labour Day", time.May, 1),
+			Easter("Ascension Day", 39),
+			Easter("Whit Monday", 50),
+			Fixed("German Unity Day", time.October, 3),
+			Fixed("Christmas Day", time.December, 25),
+			Fixed("Second Day of Christmas", time.December, 26),
+		},
+	},
+	{
+		Code:  "CZ",
+		Names: []string{"Czechia", "Czech Republic", "Česko"},
+		Rules: []Rule{
+			Fixed("New Year's Day", time.January, 1),
+			Easter("Good Friday", -2).Since(2016),
+			Easter("Easter Monday", 1),
+			Fixed("Labour Day", time.May, 1),
+			Fixed("Liberation Day", time.May, 8),
+			Fixed("Saints Cyril and Methodius Day", time.July, 5),
+			Fixed("Jan Hus Day", time.July, 6),
+			Fixed("Statehood Day", time.September, 28),
+			Fixed("Independence Day", time.October, 28),
+			Fixed("Freedom and Democracy Day", time.November, 17),
+			Fixed("Christmas Eve", time.December, 24),
+			Fixed("Christmas Day", time.December, 25),
+			Fixed("St. Stephen's Day", time.December, 26),
+		},
+	},
+	{
+		Code:  "AT",
+		Names: []string{"Austria", "Österreich"},
+		Rules: []Rule{
+			Fixed("New Year's Day", time.January, 1),
+			Fixed("Epiphany", time.January, 6),
+			Easter("Easter Monday", 1),
+			Fixed("Labour Day", time.May, 1),
+			Easter("Ascension Day", 39),
+			Easter("Whit Monday", 50),
+			Easter("Corpus Christi", 60),
+			Fixed("Assumption Day", time.August, 15),
+			Fixed("National Day", time.October, 26),
+			Fixed("All Saints' Day", time.November, 1),
+			Fixed("Immaculate Conception", time.December, 8),
+			Fixed("Christmas Day", time.December, 25),
+			Fixed("St. Stephen's Day", time.December, 26),
+		},
+	},
+	{
+		Code:  "FR",
+		Names: []string{"France"},
+		Rules: []Rule{
+			Fixed("New Year's Day", time.January, 1),
+			Easter("Easter Monday", 1),
+			Fixed("Labour Day", time.May, 1),
+			Fixed("Victory in Europe Day", time.May, 8),
+			Easter("Ascension Day", 39),
+			Easter("Whit Monday", 50),
+			Fixed("Bastille Day", time.July, 14),
+			Fixed("Assumption Day", time.August, 15),
+			Fixed("All Saints' Day", time.November, 1),
+			Fixed("Armistice Day", time.November, 11),
+			Fixed("Christmas Day", time.December, 25),
+		},
+	},
+	{
+		Code:  "IT",
+		Names: []string{"Italy", "Italia"},
+		Rules: []Rule{
+			Fixed("New Year's Day", time.January, 1),
+			Fixed("Epiphany", time.January, 6),
+			Easter("Easter Sunday", 0),
+			Easter("Easter Monday", 1),
+			Fixed("Liberation Day", time.April, 25),
+			Fixed("Labour Day", time.May, 1),
+			Fixed("Republic Day", time.June, 2),
+			Fixed("Assumption Day", time.August, 15),
+			Fixed("All Saints' Day", time.November, 1),
+			Fixed("Immaculate Conception", time.December, 8),
+			Fixed("Christmas Day", time.December, 25),
+			Fixed("St. Stephen's Day", time.December, 26),
+		},
+	},
+	{
+		Code:  "ES",
+		Names: []string{"Spain", "España"},
+		Rules: []Rule{
+			Fixed("New Year's Day", time.January, 1),
+			Fixed("Epiphany", time.January, 6),
+			Easter("Good Friday", -2),
+			Fixed("Labour Day", time.May, 1),
+			Fixed("Assumption Day", time.August, 15),
+			Fixed("National Day", time.October, 12),
+			Fixed("All Saints' Day", time.November, 1),
+			Fixed("Constitution Day", time.December, 6),
+			Fixed("Immaculate Conception", time.December, 8),
+			Fixed("Christmas Day", time.December, 25),
+		},
+	},
+	{
+		// bank holidays of England and Wales
+		Code:  "GB",
+		Names: []string{"United Kingdom", "UK"},
+		Rules: []Rule{
+			Fixed("New Year's Day", time.January, 1),
+			Easter("Good Friday", -2),
+			Easter("Easter Monday", 1),
+			Nth("Early May Bank Holiday", time.May, time.Monday, 1),
+			Nth("Spring Bank Holiday", time.May, time.Monday, -1),
+			Nth("Summer Bank Holiday", time.August, time.Monday, -1),
+			Fixed("Christmas Day", time.December, 25),
+			Fixed("Boxing Day", time.December, 26),
+		},
+	},
+	{
+		Code:  "US",
+		Names: []string{"United States", "USA"},
+		Rules: []Rule{
+			Fixed("New Year's Day", time.January, 1),
+			Nth("Martin Luther King Jr. Day", time.January, time.Monday, 3),
+			Nth("Washington's Birthday", time.February, time.Monday, 3),
+			Nth("Memorial Day", time.May, time.Monday, -1),
+			Fixed("Juneteenth", time.June, 19).Since(2021),
+			Fixed("Independence Day", time.July, 4),
+			Nth("Labor Day", time.September, time.Monday, 1),
+			Nth("Columbus Day", time.October, time.Monday, 2),
+			Fixed("Veterans Day", time.November, 11),
+			Nth("Thanksgiving Day", time.November, time.Thursday, 4),
+			Fixed("Christmas Day", time.December, 25),
+		},
+	},
+}
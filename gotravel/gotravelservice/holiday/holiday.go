@@ -0,0 +1,127 @@
+// Package holiday implements rule-based public holiday calendars of
+// countries, including movable feasts dated relative to Easter.
+package holiday
+
+import (
+	"strings"
+	"time"
+)
+
+// Rule dates a public holiday in every year it is observed.
+type Rule struct {
+	Name string
+	// From is the first year the holiday is observed, 0 for always.
+	From int
+	date func(year int) time.Time
+}
+
+// Fixed is a holiday on the same day of month every year.
+func Fixed(name string, month time.Month, day int) Rule {
+	return Rule{Name: name, date: func(year int) time.Time {
+		return date(year, month, day)
+	}}
+}
+
+// Easter is a holiday offset days after Easter Sunday, negative for days
+// before it.
+func Easter(name string, offset int) Rule {
+	return Rule{Name: name, date: func(year int) time.Time {
+		return EasterSunday(year).AddDate(0, 0, offset)
+	}}
+}
+
+// Nth is a holiday on the n-th weekday of month, negative n counts from the
+// end of month, like -1 for the last Monday.
+func Nth(name string, month time.Month, weekday time.Weekday, n int) Rule {
+	return Rule{Name: name, date: func(year int) time.Time {
+		if n < 0 {
+			last := date(year, month+1, 0)
+			back := (int(last.Weekday()-weekday) + 7) % 7
+			return last.AddDate(0, 0, -back+7*(n+1))
+		}
+		first := date(year, month, 1)
+		return first.AddDate(0, 0, (int(weekday-first.Weekday())+7)%7+7*(n-1))
+	}}
+}
+
+// Since returns the rule observed from year on.
+func (r Rule) Since(year int) Rule {
+	r.From = year
+	return r
+}
+
+// Date returns date of the holiday in year and whether it is observed then.
+func (r Rule) Date(year int) (time.Time, bool) {
+	if year < r.From {
+		return time.Time{}, false
+	}
+	return r.date(year), true
+}
+
+// Calendar is a set of nationwide public holidays of a country.
+type Calendar struct {
+	// Code is ISO 3166-1 alpha-2 code of the country.
+	Code string
+	// Names are names of the country used in addresses.
+	Names []string
+	Rules []Rule
+}
+
+// Holiday returns name of public holiday on day's date, if there is one.
+func (c *Calendar) Holiday(day time.Time) (string, bool) {
+	y, m, d := day.Date()
+	for _, r := range c.Rules {
+		if h, ok := r.Date(y); ok && h.Month() == m && h.Day() == d {
+			return r.Name, true
+		}
+	}
+	return "", false
+}
+
+// EasterSunday returns date of Easter Sunday in Gregorian calendar.
+func EasterSunday(year int) time.Time {
+	a := year % 19
+	b, c := year/100, year%100
+	d, e := b/4, b%4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i, k := c/4, c%4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return date(year, time.Month(month), day)
+}
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// Lookup returns calendar of country given by code or name.
+func Lookup(country string) (*Calendar, bool) {
+	country = strings.ToLower(strings.TrimSpace(country))
+	for _, c := range Calendars {
+		if strings.ToLower(c.Code) == country {
+			return c, true
+		}
+		for _, n := range c.Names {
+			if strings.ToLower(n) == country {
+				return c, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// ForAddress returns calendar of the country address is in, looked up by
+// comma separated parts of address starting from the last one.
+func ForAddress(address string) (*Calendar, bool) {
+	parts := strings.Split(address, ",")
+	for i := len(parts) - 1; i >= 0; i-- {
+		if c, ok := Lookup(parts[i]); ok {
+			return c, true
+		}
+	}
+	return nil, false
+}
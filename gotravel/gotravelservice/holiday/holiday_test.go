@@ -0,0 +1,105 @@
+package holiday
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEasterSunday(t *testing.T) {
+	tests := []struct {
+		year  int
+		month time.Month
+		day   int
+	}{
+		{2000, time.April, 23},
+		{2008, time.March, 23},
+		{2019, time.April, 21},
+		{2024, time.March, 31},
+		{2025, time.April, 20},
+		{2038, time.April, 25},
+	}
+	for _, tt := range tests {
+		if got := EasterSunday(tt.year); !got.Equal(date(tt.year, tt.month, tt.day)) {
+			t.Errorf("EasterSunday(%d) = %s, want %d-%02d-%02d", tt.year, got.Format("2006-01-02"), tt.year, tt.month, tt.day)
+		}
+	}
+}
+
+func TestRuleDate(t *testing.T) {
+	tests := []struct {
+		name string
+		rule Rule
+		year int
+		want time.Time
+		ok   bool
+	}{
+		{"fixed", Fixed("Labour Day", time.May, 1), 2024, date(2024, time.May, 1), true},
+		{"after Easter", Easter("Corpus Christi", 60), 2024, date(2024, time.May, 30), true},
+		{"before Easter", Easter("Good Friday", -2), 2025, date(2025, time.April, 18), true},
+		{"first Monday", Nth("Early May Bank Holiday", time.May, time.Monday, 1), 2024, date(2024, time.May, 6), true},
+		{"third Monday", Nth("Martin Luther King Jr. Day", time.January, time.Monday, 3), 2024, date(2024, time.January, 15), true},
+		{"Thanksgiving 2024", Nth("Thanksgiving Day", time.November, time.Thursday, 4), 2024, date(2024, time.November, 28), true},
+		{"Thanksgiving 2025", Nth("Thanksgiving Day", time.November, time.Thursday, 4), 2025, date(2025, time.November, 27), true},
+		{"last Monday", Nth("Memorial Day", time.May, time.Monday, -1), 2024, date(2024, time.May, 27), true},
+		{"last Monday on last day", Nth("Memorial Day", time.May, time.Monday, -1), 2021, date(2021, time.May, 31), true},
+		{"last Monday of August", Nth("Summer Bank Holiday", time.August, time.Monday, -1), 2025, date(2025, time.August, 25), true},
+		{"second to last Friday", Nth("", time.June, time.Friday, -2), 2024, date(2024, time.June, 21), true},
+		{"not yet observed", Fixed("Christmas Eve", time.December, 24).Since(2025), 2024, time.Time{}, false},
+		{"observed since", Fixed("Christmas Eve", time.December, 24).Since(2025), 2025, date(2025, time.December, 24), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.rule.Date(tt.year)
+			if ok != tt.ok || !got.Equal(tt.want) {
+				t.Errorf("Date(%d) = %s, %v, want %s, %v", tt.year, got.Format("2006-01-02"), ok, tt.want.Format("2006-01-02"), tt.ok)
+			}
+		})
+	}
+}
+
+func TestCalendarHoliday(t *testing.T) {
+	pl, ok := Lookup("pl")
+	if !ok {
+		t.Fatal("no calendar of Poland")
+	}
+	tests := []struct {
+		day  time.Time
+		name string
+	}{
+		{time.Date(2024, time.May, 30, 15, 0, 0, 0, time.FixedZone("+2", 2*60*60)), "Corpus Christi"},
+		{date(2024, time.April, 1), "Easter Monday"},
+		{date(2024, time.December, 24), ""},
+		{date(2025, time.December, 24), "Christmas Eve"},
+		{date(2024, time.June, 4), ""},
+	}
+	for _, tt := range tests {
+		name, ok := pl.Holiday(tt.day)
+		if name != tt.name || ok != (tt.name != "") {
+			t.Errorf("Holiday(%s) = %q, %v, want %q", tt.day.Format("2006-01-02"), name, ok, tt.name)
+		}
+	}
+}
+
+func TestForAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		code    string
+	}{
+		{"Na Grobli 17, 50-421 Wrocław, Poland", "PL"},
+		{"Rynek 1, 50-101 Wrocław, Polska", "PL"},
+		{"Pariser Platz, 10117 Berlin, Deutschland", "DE"},
+		{"1600 Pennsylvania Avenue NW, Washington, DC 20500, USA", "US"},
+		{"Avenue Anatole France, 75007 Paris, france ", "FR"},
+		{"Malostranské náměstí, 118 00 Praha, Czech Republic", "CZ"},
+		// country is looked up from the last part of address
+		{"Poland Street, London, UK", "GB"},
+		{"Na Grobli 17, 50-421 Wrocław", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		c, ok := ForAddress(tt.address)
+		if ok != (tt.code != "") || (ok && c.Code != tt.code) {
+			t.Errorf("ForAddress(%q) = %v, %v, want %q", tt.address, c, ok, tt.code)
+		}
+	}
+}
@@ -5,34 +5,74 @@ import (
 	"time"
 
 	"github.com/go-kit/kit/log"
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/tenant"
 	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/trip"
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/usage"
 )
 
 // Middleware is a service middleware, similar to endpoint middleware
 type Middleware func(Service) Service
 
 // NewLoggingMiddleware given a logger returns a service middleware
-// that logs service methods calls
-func NewLoggingMiddleware(logger log.Logger) Middleware {
+// that logs service methods calls with name of the tenant their token
+// authenticates in tenants, which may be nil
+func NewLoggingMiddleware(logger log.Logger, tenants *tenant.Registry) Middleware {
 	return func(next Service) Service {
-		return loggingMiddleware{logger, next}
+		return loggingMiddleware{logger, tenants, next}
 	}
 }
 
 type loggingMiddleware struct {
-	logger log.Logger
-	next   Service
+	logger  log.Logger
+	tenants *tenant.Registry
+	next    Service
+}
+
+// tenant returns name of the tenant of request, which is not authenticated
+// yet when logging wraps authentication.
+func (mw loggingMiddleware) tenant(ctx context.Context) string {
+	if t, ok := mw.tenants.Lookup(tenant.TokenFromContext(ctx)); ok {
+		return t.Name
+	}
+	return tenantName(ctx)
 }
 
 func (mw loggingMiddleware) TripPlan(ctx context.Context, tc trip.Configuration) (t trip.Trip, err error) {
 	defer func(begin time.Time) {
 		mw.logger.Log(
 			"method", "TripPlan",
-			"apiKey", tc.APIKey,
+			"tenant", mw.tenant(ctx),
+			"apiKey", tenant.Redact(tc.APIKey),
 			"schedule", t.Schedule,
+			"billableMatrixElements", t.Usage.MatrixElements.Billable,
 			"err", err,
 			"took", time.Since(begin),
 		)
 	}(time.Now())
 	return mw.next.TripPlan(ctx, tc)
 }
+
+func (mw loggingMiddleware) Estimate(ctx context.Context, tc trip.Configuration) (e Estimate, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "Estimate",
+			"tenant", mw.tenant(ctx),
+			"matrixElements", e.Usage.MatrixElements.Billable,
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+	return mw.next.Estimate(ctx, tc)
+}
+
+func (mw loggingMiddleware) Usage(ctx context.Context) (r usage.Report, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "Usage",
+			"tenant", mw.tenant(ctx),
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+	return mw.next.Usage(ctx)
+}
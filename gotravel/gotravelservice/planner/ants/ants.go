@@ -3,6 +3,7 @@ package ants
 import (
 	"errors"
 	"math/rand"
+	"sort"
 	"strconv"
 	"time"
 
@@ -12,26 +13,47 @@ import (
 )
 
 var (
-	ErrPlaceClosed         = errors.New("place closed at that day")
-	ErrPlaceClosesTooEarly = errors.New("place closes too early")
-	ErrTripEndsTooEarly    = errors.New("trip time ends before place departure")
-	ErrCantReachEndPlace   = errors.New("can't reach set end place in time")
-	ErrTripEnded           = errors.New("no place reachable before trip time end")
-	ErrMustReturnToStart   = errors.New("must return to start place before trip ends")
-	ErrMustReachEndPlace   = errors.New("must get to end place before trip ends")
+	ErrPlaceClosed          = errors.New("place closed at that day")
+	ErrPlaceClosesTooEarly  = errors.New("place closes too early")
+	ErrTripEndsTooEarly     = errors.New("trip time ends before place departure")
+	ErrVisitTooLate         = errors.New("place departure after its latest visit time")
+	ErrMissedAppointment    = errors.New("place arrival after its appointment")
+	ErrCantReachAppointment = errors.New("can't reach place with appointment in time")
+	ErrPredecessorMissed    = errors.New("place required to be visited before is not visited")
+	ErrBreakMissed          = errors.New("break can't be taken in time after visiting place")
+	ErrCantReachEndPlace    = errors.New("can't reach set end place in time")
+	ErrTripEnded            = errors.New("no place reachable before trip time end")
+	ErrMustReturnToStart    = errors.New("must return to start place before trip ends")
+	ErrMustReachEndPlace    = errors.New("must get to end place before trip ends")
 )
 
 type Used map[int]bool
 
+// takenBreak identifies break taken on a day by its index and start of its
+// window that day.
+type takenBreak struct {
+	index int
+	start int64
+}
+
 type Ant struct {
 	trip          *trip.Trip
+	windows       []trip.Window
+	appointments  []*trip.Place
+	required      []*trip.Place
+	reasons       []error
+	taken         map[takenBreak]bool
 	visitTimes    VisitTimes
 	startPlace    *trip.Place
 	endPlace      *trip.Place
 	n             int
 	path          trip.Path
+	paths         []trip.Path
+	days          []trip.Day
 	at            int
 	used          Used
+	dayStart      time.Time
+	dayEnd        time.Time
 	currentTime   time.Time
 	totalTime     time.Duration
 	totalDistance int64
@@ -65,60 +87,88 @@ func (a *Ant) SetPheromones(p *PheromonesMatrix) {
 	a.pheromones = p
 }
 
+// FindFood builds path of every active window of the trip, visiting every
+// place at most once, and sends the result.
 func (a *Ant) FindFood() {
-	err := a.before()
-	switch err {
-	case ErrTripEnded:
-		a.resultChannel <- NewResult(
-			a.path,
-			a.totalTime,
-			a.totalDistance,
-			a.sumPriorities(),
-			a.visitTimes,
-		)
-	case nil:
-		err = a.generatePath()
-		if err != nil && err != ErrTripEnded {
+	a.reset()
+	for d, w := range a.windows {
+		a.dayStart, a.dayEnd = w.Start, w.End
+		day := trip.Day{Start: w.Start, End: w.Start}
+		switch err := a.before(d); err {
+		case ErrTripEnded:
+		case nil:
+			err = a.generatePath()
+			if err != nil && err != ErrTripEnded {
+				panic(err.Error())
+			}
+			a.paths = append(a.paths, a.path)
+			day.End, day.Path, day.Steps = a.currentTime, a.path.Path(), a.path.Steps
+		default:
 			panic(err.Error())
 		}
-		a.resultChannel <- NewResult(
-			a.path,
-			a.totalTime,
-			a.totalDistance,
-			a.sumPriorities(),
-			a.visitTimes,
-		)
-	default:
-		panic(err.Error())
+		a.days = append(a.days, day)
+	}
+	if l := a.trip.Lodging; l != nil {
+		a.visitTimes.Arrivals[l.Index] = a.days[0].Start
+		a.visitTimes.Departures[l.Index] = a.days[len(a.days)-1].End
+	}
+	var missed []Miss
+	for _, p := range a.required {
+		if !a.isUsed(p) {
+			reason := a.reasons[p.Index]
+			if reason == nil {
+				reason = ErrTripEnded
+			}
+			missed = append(missed, Miss{Index: p.Index, Reason: reason})
+		}
 	}
+	a.resultChannel <- NewResult(
+		a.paths,
+		a.days,
+		missed,
+		a.totalTime,
+		a.totalDistance,
+		a.sumPriorities(),
+		a.visitTimes,
+	)
 }
 
 func (a *Ant) setStart() error {
-	if a.trip.StartPlace == nil {
-		var reachable []*trip.Place
+	var reachable []*trip.Place
 
-		for _, p := range a.trip.Places {
-			a.at = p.Index
-			if ok, _ := a.placeReachable(p); ok && p != a.endPlace {
-				reachable = append(reachable, p)
-			}
+	for _, p := range a.trip.Places {
+		a.at = p.Index
+		if a.isUsed(p) || p == a.endPlace || p == a.trip.EndPlace || !a.predecessorsUsed(p) {
+			continue
 		}
-		if n := len(reachable); n > 0 {
-			i := a.random.Intn(n)
-			a.startPlace = reachable[i]
-		} else if a.endPlace != nil {
-			a.startPlace = a.endPlace
+		if ok, err := a.placeReachable(p, true); ok {
+			reachable = append(reachable, p)
 		} else {
-			return ErrTripEnded
+			a.reject(p, err)
 		}
+	}
+	if n := len(reachable); n > 0 {
+		i := a.random.Intn(n)
+		a.startPlace = reachable[i]
+	} else if a.endPlace != nil {
+		a.startPlace = a.endPlace
 	} else {
-		a.startPlace = a.trip.StartPlace
+		return ErrTripEnded
 	}
 	return nil
 }
 
 func (a *Ant) init() {
 	a.n = len(a.trip.Places)
+	a.windows = a.trip.ActiveWindows()
+	for _, p := range a.trip.Places {
+		if p.Appointment != nil {
+			a.appointments = append(a.appointments, p)
+		}
+		if p.Required || p.Appointment != nil {
+			a.required = append(a.required, p)
+		}
+	}
 	a.random = rand.New(rand.NewSource(time.Now().UnixNano()))
 }
 
@@ -126,7 +176,7 @@ func (a *Ant) setStep(i int, place *trip.Place) {
 	var dist int64
 	var dur time.Duration
 	arrival, departure, err := a.placeArrivalDeparture(place, i == 0)
-	if err != nil {
+	if err != nil && place != a.endPlace {
 		panic(err.Error())
 	}
 	if i > 0 {
@@ -153,15 +203,56 @@ func (a *Ant) isUsed(place *trip.Place) bool {
 	return a.used[place.Index]
 }
 
-func (a *Ant) before() error {
-	a.endPlace = a.trip.EndPlace
+// predecessorsUsed checks whether all places required before place are
+// already visited.
+func (a *Ant) predecessorsUsed(place *trip.Place) bool {
+	for _, i := range place.Predecessors {
+		if !a.used[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// reject keeps the first reason place couldn't be visited next, later ones
+// are often just consequences of time running out.
+func (a *Ant) reject(place *trip.Place, reason error) {
+	if a.reasons[place.Index] == nil {
+		a.reasons[place.Index] = reason
+	}
+}
+
+func (a *Ant) reset() {
 	a.visitTimes = NewVisitTimes(a.n)
 	a.used = make(Used, a.n)
-	a.currentTime = a.trip.TripStart
+	a.reasons = make([]error, a.n)
+	a.taken = make(map[takenBreak]bool)
+	a.paths = nil
+	a.days = nil
 	a.totalTime = time.Duration(0)
 	a.totalDistance = 0
-	if err := a.setStart(); err != nil {
-		return err
+}
+
+// before sets start and end places of d-th day and steps into the start
+// place. Days of trip with lodging start and end there, otherwise start
+// place of the trip is used on the first day and end place on the last one.
+func (a *Ant) before(d int) error {
+	a.currentTime = a.dayStart
+	a.startPlace, a.endPlace = nil, nil
+	if a.trip.Lodging != nil {
+		a.startPlace, a.endPlace = a.trip.Lodging, a.trip.Lodging
+	} else {
+		if d == 0 {
+			a.startPlace = a.trip.StartPlace
+		}
+		if d == len(a.windows)-1 {
+			a.endPlace = a.trip.EndPlace
+		}
+	}
+	if a.startPlace == nil {
+		if err := a.setStart(); err != nil {
+			return err
+		}
 	}
 	a.path = trip.NewPath(a.n, a.startPlace == a.endPlace)
 	a.setStep(0, a.startPlace)
@@ -170,10 +261,16 @@ func (a *Ant) before() error {
 
 func (a *Ant) generatePath() error {
 	for i := 1; i < a.n; i++ {
-		switch next, err := a.pickNextPlace(); err {
+		a.takeBreaks(false)
+		next, err := a.pickNextPlace()
+		if err != nil && a.takeBreaks(true) {
+			i--
+			continue
+		}
+		switch err {
 		case ErrMustReachEndPlace:
 			a.setStep(i, next)
-			if i+1 < a.path.Size()-1 {
+			if i+1 < a.path.Size() {
 				a.path.Cut(i + 1)
 			}
 			return ErrTripEnded
@@ -181,10 +278,10 @@ func (a *Ant) generatePath() error {
 			a.path.Cut(i)
 			return ErrTripEnded
 		case ErrMustReturnToStart:
-			if i < a.path.Size()-1 {
-				a.path.Cut(i)
+			a.path.Cut(i)
+			if next.Index != a.at {
+				a.setStep(i, next)
 			}
-			a.setStep(i, next)
 			return ErrTripEnded
 		case nil:
 			a.setStep(i, next)
@@ -206,18 +303,25 @@ func (a *Ant) generatePath() error {
 func (a *Ant) pickNextPlace() (place *trip.Place, err error) {
 	var available []*trip.Place
 	for _, p := range a.trip.Places {
-		if !a.isUsed(p) && p != a.endPlace {
+		if a.isUsed(p) || p == a.endPlace || p == a.trip.EndPlace {
+			continue
+		}
+		if a.predecessorsUsed(p) {
 			available = append(available, p)
+		} else {
+			a.reject(p, ErrPredecessorMissed)
 		}
 	}
 	var reachable []*trip.Place
 	var pheromones []float64
 
 	for _, p := range available {
-		if ok, _ := a.placeReachable(p); ok {
+		if ok, err := a.placeReachable(p, false); ok {
 			reachable = append(reachable, p)
 			pheromone := a.pheromones.At(a.at, p.Index)
 			pheromones = append(pheromones, pheromone)
+		} else {
+			a.reject(p, err)
 		}
 	}
 	l := len(reachable)
@@ -240,7 +344,6 @@ func (a *Ant) pickNextPlace() (place *trip.Place, err error) {
 }
 
 func (a *Ant) placeArrivalDeparture(place *trip.Place, first bool) (arrival, departure time.Time, err error) {
-	var opn, cls time.Time
 	if first {
 		arrival = a.currentTime
 	} else {
@@ -252,63 +355,189 @@ func (a *Ant) placeArrivalDeparture(place *trip.Place, first bool) (arrival, dep
 		return arrival, arrival, nil
 	}
 
-	departure = arrival.Add(time.Duration(place.StayDuration) * time.Minute)
-	oc := place.Details.OpeningHoursPeriods[a.currentTime.Weekday()]
-	if oc.Open == "" && oc.Close == "" {
-		return arrival, departure, ErrPlaceClosed
+	departure, err = a.visit(place, arrival)
+	return
+}
+
+// visit returns departure from place arrived at given time, waiting for its
+// opening, earliest visit time or appointment if needed. The stay has to fit
+// in one of opening periods of the day, the first one it fits in is used.
+func (a *Ant) visit(place *trip.Place, arrival time.Time) (departure time.Time, err error) {
+	stay := time.Duration(place.StayDuration) * time.Minute
+	departure = arrival.Add(stay)
+	periods := openPeriods(place, arrival)
+	if len(periods) == 0 {
+		return departure, ErrPlaceClosed
 	}
-	{
-		o := oc.Open
-		y, m, d := arrival.In(place.Details.Location).Date()
-		hh, _ := strconv.Atoi(o[:2])
-		mm, _ := strconv.Atoi(o[2:])
-		opn = time.Date(y, m, d, hh, mm, 0, 0, place.Details.Location).In(arrival.Location())
+	earliest := arrival
+	if place.Earliest != "" {
+		if e := clockAt(arrival, place.Earliest, place.Details.Location); e.After(earliest) {
+			earliest = e
+		}
+	}
+	if ap := place.Appointment; ap != nil && ap.Start.After(earliest) {
+		earliest = ap.Start
+	}
+	err = ErrPlaceClosesTooEarly
+	var start time.Time
+	for _, w := range periods {
+		start = w.Start
+		if earliest.After(start) {
+			start = earliest
+		}
+		if !w.End.Before(start.Add(stay)) {
+			departure, err = start.Add(stay), nil
+			break
+		}
 	}
-	if opn.After(arrival) {
-		departure = opn.Add(time.Duration(place.StayDuration) * time.Minute)
+	if err != nil {
+		return departure, err
 	}
-	{
-		c := oc.Close
-		y, m, d := departure.In(place.Details.Location).Date()
-		hh, _ := strconv.Atoi(c[:2])
-		mm, _ := strconv.Atoi(c[2:])
-		cls = time.Date(y, m, d, hh, mm, 0, 0, place.Details.Location).In(arrival.Location())
+	if ap := place.Appointment; ap != nil && start.After(ap.End) {
+		return departure, ErrMissedAppointment
 	}
-	if cls.Before(departure) {
-		return arrival, departure, ErrPlaceClosesTooEarly
+	if place.Latest != "" && clockAt(departure, place.Latest, place.Details.Location).Before(departure) {
+		return departure, ErrVisitTooLate
 	}
-	if a.trip.TripEnd.Before(departure) {
-		return arrival, departure, ErrTripEndsTooEarly
+	if a.dayEnd.Before(departure) {
+		return departure, ErrTripEndsTooEarly
 	}
 	return
 }
 
-func (a *Ant) placeReachable(place *trip.Place) (ok bool, err error) {
+// openPeriods returns opening periods of place as time ranges that end after
+// arrival, including periods opened on previous days. Periods of the next day
+// are included too, so that a visit can wait for opening after midnight, the
+// wait is limited by the end of the day window in visit. Ranges that touch
+// each other, like opening hours of every day of place that is always open,
+// are joined.
+func openPeriods(place *trip.Place, arrival time.Time) (periods []trip.Window) {
+	loc := place.Details.Location
+	day := arrival.In(loc)
+	for k := 7; k >= -1; k-- {
+		d := day.AddDate(0, 0, -k)
+		for _, oc := range place.Details.PeriodsOn(d) {
+			if oc.CloseDay < k {
+				continue
+			}
+			w := trip.Window{
+				Start: clockAt(d, oc.Open, loc),
+				End:   clockAt(d.AddDate(0, 0, oc.CloseDay), oc.Close, loc),
+			}
+			if w.End.After(arrival) {
+				periods = append(periods, w)
+			}
+		}
+	}
+	sort.Slice(periods, func(i, j int) bool {
+		return periods[i].Start.Before(periods[j].Start)
+	})
+	var joined []trip.Window
+	for _, w := range periods {
+		if n := len(joined); n > 0 && !w.Start.After(joined[n-1].End) {
+			if w.End.After(joined[n-1].End) {
+				joined[n-1].End = w.End
+			}
+			continue
+		}
+		joined = append(joined, w)
+	}
+	return joined
+}
+
+// clockAt returns time of day given in 'hhmm' format at date of t in loc.
+func clockAt(t time.Time, hhmm string, loc *time.Location) time.Time {
+	y, m, d := t.In(loc).Date()
+	hh, _ := strconv.Atoi(hhmm[:2])
+	mm, _ := strconv.Atoi(hhmm[2:])
+	return time.Date(y, m, d, hh, mm, 0, 0, loc).In(t.Location())
+}
+
+// placeReachable checks whether place can be visited next, or first on the
+// day, and the end place, places with appointments later that day and breaks
+// of the day can still be reached or taken in time after that.
+func (a *Ant) placeReachable(place *trip.Place, first bool) (ok bool, err error) {
 	if place.Details.PermanentlyClosed {
 		return false, ErrPlaceClosed
 	}
 
-	_, dprt, err := a.placeArrivalDeparture(place, a.currentTime.Equal(a.trip.TripStart))
+	_, dprt, err := a.placeArrivalDeparture(place, first)
 	if err != nil {
 		return false, err
 	}
 
-	if a.endPlace != nil {
+	if a.endPlace != nil && a.endPlace != place {
 		fin := dprt.Add(a.durations.At(place.Index, a.endPlace.Index, dprt))
 		if a.endPlace != a.startPlace {
-			stay := time.Duration(a.endPlace.StayDuration) * time.Minute
-			fin = fin.Add(stay)
-		}
-		if a.trip.TripEnd.Before(fin) {
+			if _, err := a.visit(a.endPlace, fin); err != nil {
+				return false, ErrCantReachEndPlace
+			}
+		} else if a.dayEnd.Before(fin) {
 			return false, ErrCantReachEndPlace
 		}
 	}
+
+	for i, b := range a.trip.Breaks {
+		if _, latest, ok := a.breakWindow(i, b); ok && latest.Before(dprt) {
+			return false, ErrBreakMissed
+		}
+	}
+
+	for _, p := range a.appointments {
+		ap := p.Appointment
+		if p == place || a.isUsed(p) || ap.End.Before(a.dayStart) || !ap.Start.Before(a.dayEnd) {
+			continue
+		}
+		if ap.End.Before(dprt.Add(a.durations.At(place.Index, p.Index, dprt))) {
+			return false, ErrCantReachAppointment
+		}
+	}
 	return true, nil
 }
 
+// breakWindow returns start and latest start of i-th break on the current day,
+// it reports false if the break is taken or can no longer be taken.
+func (a *Ant) breakWindow(i int, b trip.Break) (start, latest time.Time, ok bool) {
+	loc := a.dayStart.Location()
+	start = clockAt(a.currentTime, b.Start, loc)
+	if a.taken[takenBreak{i, start.Unix()}] {
+		return start, latest, false
+	}
+	end := clockAt(a.currentTime, b.End, loc)
+	if end.After(a.dayEnd) {
+		end = a.dayEnd
+	}
+	latest = end.Add(-time.Duration(b.Duration) * time.Minute)
+	return start, latest, !latest.Before(a.currentTime) && !latest.Before(start)
+}
+
+// takeBreaks takes every break of the current day that can be taken now, or
+// waits for start of the first one that can be taken later if wait is set.
+// It reports whether any break was taken.
+func (a *Ant) takeBreaks(wait bool) (taken bool) {
+	for i, b := range a.trip.Breaks {
+		start, _, ok := a.breakWindow(i, b)
+		if !ok || (start.After(a.currentTime) && !wait) {
+			continue
+		}
+		a.taken[takenBreak{i, start.Unix()}] = true
+		if start.Before(a.currentTime) {
+			start = a.currentTime
+		}
+		end := start.Add(time.Duration(b.Duration) * time.Minute)
+		a.path.AddBreak(a.at, trip.TakenBreak{Name: b.Name, Start: start, End: end})
+		a.totalTime += end.Sub(a.currentTime)
+		a.currentTime = end
+		taken, wait = true, false
+	}
+	return taken
+}
+
 func (a *Ant) sumPriorities() (sum int) {
-	for _, i := range a.path.Path() {
-		sum += a.trip.Places[i].Priority
+	for _, p := range a.paths {
+		for _, i := range p.Path() {
+			sum += a.trip.Places[i].Priority
+		}
 	}
 	return
 }
@@ -0,0 +1,350 @@
+package ants
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/trip"
+)
+
+// runs is number of paths an ant finds in every test, each of them has to
+// respect the constraints.
+const runs = 200
+
+// at returns time of day of June 4th 2024, a Tuesday, days later in UTC.
+func at(days, hh, mm int) time.Time {
+	return time.Date(2024, time.June, 4+days, hh, mm, 0, 0, time.UTC)
+}
+
+// testTrip returns trip between 09:00 and 17:00 of places with given stays
+// in minutes, all always open.
+func testTrip(stays ...int) *trip.Trip {
+	tr := &trip.Trip{TripStart: at(0, 9, 0), TripEnd: at(0, 17, 0)}
+	for i, stay := range stays {
+		hours := make(map[time.Weekday][]trip.OpeningHours, 7)
+		for d := time.Sunday; d <= time.Saturday; d++ {
+			hours[d] = []trip.OpeningHours{{Open: "0000", Close: "0000", CloseDay: 1}}
+		}
+		tr.Places = append(tr.Places, &trip.Place{
+			Index:        i,
+			StayDuration: stay,
+			Priority:     1,
+			Details: trip.PlaceDetails{
+				Name:                fmt.Sprintf("place %d", i),
+				OpeningHoursPeriods: hours,
+				Location:            time.UTC,
+			},
+		})
+	}
+	return tr
+}
+
+// findFood returns results of ant searching paths of the trip, travel
+// between any two places takes 30 minutes.
+func findFood(tr *trip.Trip) []Result {
+	n := len(tr.Places)
+	times := []time.Time{tr.ActiveWindows()[0].Start}
+	durations := NewTravelTimeMatrix(n, times)
+	distances := NewDistanceMatrix(n, times)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			if i != j {
+				durations.Set(i, j, times[0], 30*time.Minute)
+				distances.Set(i, j, times[0], 1000)
+			}
+		}
+	}
+	resultChannel := make(chan Result, 1)
+	a := NewAnt(tr, distances, durations, NewPheromonesMatrix(n, 1), resultChannel)
+	results := make([]Result, runs)
+	for i := range results {
+		a.FindFood()
+		results[i] = <-resultChannel
+	}
+	return results
+}
+
+// visited returns visit of every place of the result, as its start and
+// departure, on days it is visited at.
+func visited(t *testing.T, tr *trip.Trip, r Result) (starts, departures map[int]time.Time, days map[int]int) {
+	t.Helper()
+	starts, departures, days = make(map[int]time.Time), make(map[int]time.Time), make(map[int]int)
+	times := r.VisitTimes()
+	for d, day := range r.Days() {
+		for _, i := range day.Path {
+			if tr.Lodging != nil && i == tr.Lodging.Index {
+				continue
+			}
+			if _, ok := days[i]; ok {
+				t.Errorf("place %d visited on days %d and %d", i, days[i], d)
+			}
+			days[i] = d
+			departures[i] = times.Departures[i]
+			starts[i] = departures[i].Add(-time.Duration(tr.Places[i].StayDuration) * time.Minute)
+		}
+	}
+	return starts, departures, days
+}
+
+func TestFindFoodDays(t *testing.T) {
+	windows, err := trip.DailyWindows(at(0, 9, 0), at(1, 17, 0), "09:00", "17:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("lodging", func(t *testing.T) {
+		// only two places fit in a day between leaving and returning to lodging
+		tr := testTrip(0, 180, 180, 180, 180)
+		tr.Windows = windows
+		tr.Lodging = tr.Places[0]
+		tr.Lodging.SetLodging()
+		for _, r := range findFood(tr) {
+			days := r.Days()
+			if len(days) != len(windows) {
+				t.Fatalf("%d days planned, want %d", len(days), len(windows))
+			}
+			for d, day := range days {
+				if !day.Start.Equal(windows[d].Start) || day.End.After(windows[d].End) {
+					t.Errorf("day %d lasts %v - %v, outside of its window", d, day.Start, day.End)
+				}
+				if day.Path[0] != 0 || day.Steps[len(day.Steps)-1].To != 0 {
+					t.Errorf("day %d path %v with steps %v doesn't start and end at lodging", d, day.Path, day.Steps)
+				}
+				if len(day.Path) != 3 {
+					t.Errorf("day %d path %v, want lodging and two places", d, day.Path)
+				}
+			}
+			starts, departures, placeDays := visited(t, tr, r)
+			if len(placeDays) != 4 {
+				t.Errorf("places visited on days %v, want all 4", placeDays)
+			}
+			for i, d := range placeDays {
+				if starts[i].Before(windows[d].Start) || departures[i].After(windows[d].End) {
+					t.Errorf("place %d visited %v - %v outside of day %d", i, starts[i], departures[i], d)
+				}
+			}
+			times := r.VisitTimes()
+			if !times.Arrivals[0].Equal(windows[0].Start) || !times.Departures[0].Equal(days[1].End) {
+				t.Errorf("lodging visited %v - %v, want from trip start to the last return", times.Arrivals[0], times.Departures[0])
+			}
+		}
+	})
+
+	t.Run("without lodging", func(t *testing.T) {
+		// one place fits in a shorter day
+		short, err := trip.DailyWindows(at(0, 9, 0), at(1, 17, 0), "09:00", "12:00")
+		if err != nil {
+			t.Fatal(err)
+		}
+		tr := testTrip(120, 120, 120)
+		tr.Windows = short
+		for _, r := range findFood(tr) {
+			if len(r.Days()) != len(short) {
+				t.Fatalf("%d days planned, want %d", len(r.Days()), len(short))
+			}
+			starts, departures, placeDays := visited(t, tr, r)
+			if len(placeDays) != 2 {
+				t.Errorf("places visited on days %v, want one every day", placeDays)
+			}
+			for i, d := range placeDays {
+				if starts[i].Before(short[d].Start) || departures[i].After(short[d].End) {
+					t.Errorf("place %d visited %v - %v outside of day %d", i, starts[i], departures[i], d)
+				}
+			}
+		}
+	})
+}
+
+func TestFindFoodVisitWindows(t *testing.T) {
+	tr := testTrip(60, 120, 60, 60)
+	tr.Places[0].Earliest, tr.Places[0].Latest = "1300", "1500"
+	tr.Places[1].Latest = "1000"
+	tr.Places[1].Required = true
+	// visit window of place 3 is in its own time zone, 11:00 - 12:00 UTC
+	tr.Places[3].Details.Location = time.FixedZone("+2", 2*60*60)
+	tr.Places[3].Earliest, tr.Places[3].Latest = "1300", "1400"
+
+	for _, r := range findFood(tr) {
+		starts, departures, _ := visited(t, tr, r)
+		if _, ok := starts[0]; !ok {
+			t.Error("place 0 not visited")
+		} else if starts[0].Before(at(0, 13, 0)) || departures[0].After(at(0, 15, 0)) {
+			t.Errorf("place 0 visited %v - %v, want between 13:00 and 15:00", starts[0], departures[0])
+		}
+		if _, ok := starts[1]; ok {
+			t.Errorf("place 1 visited %v - %v, after its latest visit time", starts[1], departures[1])
+		}
+		if _, ok := starts[3]; ok && (!starts[3].Equal(at(0, 11, 0)) || !departures[3].Equal(at(0, 12, 0))) {
+			t.Errorf("place 3 visited %v - %v, want 11:00 - 12:00 UTC", starts[3], departures[3])
+		}
+		if missed := r.Missed(); len(missed) != 1 || missed[0] != (Miss{1, ErrVisitTooLate}) {
+			t.Errorf("missed %v, want place 1 visited too late", missed)
+		}
+	}
+}
+
+func TestFindFoodOpeningPeriods(t *testing.T) {
+	tr := testTrip(150, 60, 60)
+	// stay of place 0 fits its morning period only before the trip starts
+	tr.TripStart, tr.TripEnd = at(0, 10, 0), at(0, 18, 0)
+	hours := make(map[time.Weekday][]trip.OpeningHours, 7)
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		hours[d] = []trip.OpeningHours{{Open: "0900", Close: "1200"}, {Open: "1400", Close: "1800"}}
+	}
+	tr.Places[0].Details.OpeningHoursPeriods = hours
+
+	for _, r := range findFood(tr) {
+		starts, departures, _ := visited(t, tr, r)
+		if _, ok := starts[0]; !ok {
+			t.Errorf("place 0 not visited in path %v", r.path.Path())
+		} else if starts[0].Before(at(0, 14, 0)) || departures[0].After(at(0, 18, 0)) {
+			t.Errorf("place 0 visited %v - %v, want within afternoon period 14:00 - 18:00", starts[0], departures[0])
+		}
+	}
+}
+
+func TestFindFoodAppointments(t *testing.T) {
+	tr := testTrip(60, 300, 60, 30)
+	// visiting place 1 before or after the appointment takes too long,
+	// appointment of place 3 is before the trip starts
+	tr.Places[0].Appointment = &trip.Window{Start: at(0, 11, 0), End: at(0, 11, 15)}
+	tr.Places[3].Appointment = &trip.Window{Start: at(0, 8, 0), End: at(0, 8, 10)}
+
+	for _, r := range findFood(tr) {
+		starts, departures, _ := visited(t, tr, r)
+		if _, ok := starts[0]; !ok {
+			t.Error("place with appointment not visited")
+		} else if starts[0].Before(at(0, 11, 0)) || starts[0].After(at(0, 11, 15)) {
+			t.Errorf("place with appointment visited %v - %v, want start between 11:00 and 11:15", starts[0], departures[0])
+		}
+		if _, ok := starts[1]; ok {
+			t.Errorf("place 1 visited %v - %v, appointment can't be kept", starts[1], departures[1])
+		}
+		if missed := r.Missed(); len(missed) != 1 || missed[0] != (Miss{3, ErrMissedAppointment}) {
+			t.Errorf("missed %v, want missed appointment of place 3", missed)
+		}
+	}
+}
+
+func TestFindFoodPrecedence(t *testing.T) {
+	tr := testTrip(60, 60, 60, 60, 60)
+	tr.Places[2].Predecessors = []int{1}
+	// place 3 follows place 4 that is closed
+	tr.Places[3].Predecessors = []int{4}
+	tr.Places[3].Required = true
+	tr.Places[4].Details.PermanentlyClosed = true
+
+	for _, r := range findFood(tr) {
+		starts, departures, _ := visited(t, tr, r)
+		if _, ok := starts[2]; ok {
+			if _, ok := starts[1]; !ok || departures[1].After(starts[2]) {
+				t.Errorf("place 2 visited in path %v before its predecessor", r.path.Path())
+			}
+		}
+		if _, ok := starts[3]; ok {
+			t.Errorf("place 3 visited in path %v without its predecessor", r.path.Path())
+		}
+		if missed := r.Missed(); len(missed) != 1 || missed[0] != (Miss{3, ErrPredecessorMissed}) {
+			t.Errorf("missed %v, want missed predecessor of place 3", missed)
+		}
+	}
+}
+
+func TestFindFoodBreaks(t *testing.T) {
+	windows, err := trip.DailyWindows(at(0, 9, 0), at(1, 17, 0), "09:00", "17:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := testTrip(0, 90, 90, 90, 90, 90, 90)
+	tr.Windows = windows
+	tr.Lodging = tr.Places[0]
+	tr.Lodging.SetLodging()
+	tr.Breaks = []trip.Break{{Name: "Lunch", Duration: 45, Start: "1200", End: "1400"}}
+
+	for _, r := range findFood(tr) {
+		starts, departures, placeDays := visited(t, tr, r)
+		for d, day := range r.Days() {
+			var breaks []*trip.TakenBreak
+			for _, s := range day.Steps {
+				if s.Break != nil {
+					breaks = append(breaks, s.Break)
+				}
+			}
+			if len(breaks) != 1 {
+				t.Errorf("%d breaks taken on day %d, want 1", len(breaks), d)
+				continue
+			}
+			b := breaks[0]
+			if b.Start.Before(at(d, 12, 0)) || b.End.After(at(d, 14, 0)) || b.End.Sub(b.Start) != 45*time.Minute {
+				t.Errorf("break on day %d taken %v - %v, want 45 minutes between 12:00 and 14:00", d, b.Start, b.End)
+			}
+			for i, pd := range placeDays {
+				if pd == d && starts[i].Before(b.End) && departures[i].After(b.Start) {
+					t.Errorf("place %d visited %v - %v during break %v - %v", i, starts[i], departures[i], b.Start, b.End)
+				}
+			}
+		}
+	}
+}
+
+func TestResultBetterThan(t *testing.T) {
+	path := func(places ...int) trip.Path {
+		p := trip.NewPath(len(places), false)
+		for i, place := range places {
+			p.Set(i, place)
+		}
+		return p
+	}
+	// complete path visits fewer places of lower priority and takes much
+	// longer than paths missing a required place
+	complete := NewResult([]trip.Path{path(0, 1)}, nil, nil, 10*time.Hour, 100000, 1, VisitTimes{})
+	missing := []Result{
+		NewResult([]trip.Path{path(0, 2, 3, 4)}, nil, []Miss{{1, ErrPlaceClosed}}, time.Hour, 1000, 10, VisitTimes{}),
+		NewResult([]trip.Path{path(0)}, nil, []Miss{{1, ErrTripEnded}}, 0, 0, 0, VisitTimes{}),
+	}
+	for i, r := range missing {
+		if r.BetterThan(complete) || !complete.BetterThan(r) {
+			t.Errorf("result %d missing required place ranks above complete one", i)
+		}
+	}
+	if !missing[0].BetterThan(NewEmptyResult()) {
+		t.Error("result missing required place ranks below empty one")
+	}
+}
+
+func TestVisitOvernight(t *testing.T) {
+	tr := testTrip(30, 30)
+	// place 0 is open on Tuesday night until 02:00, place 1 only after
+	// midnight of Wednesday
+	tr.Places[0].Details.OpeningHoursPeriods = map[time.Weekday][]trip.OpeningHours{
+		time.Tuesday: {{Open: "2000", Close: "0200", CloseDay: 1}},
+	}
+	tr.Places[1].Details.OpeningHoursPeriods = map[time.Weekday][]trip.OpeningHours{
+		time.Wednesday: {{Open: "0000", Close: "0300"}},
+	}
+	tests := []struct {
+		name      string
+		place     int
+		arrival   time.Time
+		dayEnd    time.Time
+		departure time.Time
+		err       error
+	}{
+		{"before opening", 0, at(0, 19, 0), at(1, 4, 0), at(0, 20, 30), nil},
+		{"after midnight", 0, at(1, 0, 30), at(1, 4, 0), at(1, 1, 0), nil},
+		{"closing after midnight", 0, at(1, 1, 45), at(1, 4, 0), time.Time{}, ErrPlaceClosesTooEarly},
+		{"after closing", 0, at(1, 2, 30), at(1, 4, 0), time.Time{}, ErrPlaceClosed},
+		{"waiting for opening after midnight", 1, at(0, 23, 30), at(1, 4, 0), at(1, 0, 30), nil},
+		{"day ends before opening", 1, at(0, 23, 30), at(0, 23, 59), time.Time{}, ErrTripEndsTooEarly},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &Ant{dayEnd: tt.dayEnd}
+			departure, err := a.visit(tr.Places[tt.place], tt.arrival)
+			if err != tt.err || (err == nil && !departure.Equal(tt.departure)) {
+				t.Errorf("visit = %v, %v, want %v, %v", departure, err, tt.departure, tt.err)
+			}
+		})
+	}
+}
@@ -15,12 +15,12 @@ type PheromonesMatrix struct {
 	mutex  sync.Mutex
 }
 
-func NewPheromonesMatrix(n int, initial float64, mutex sync.Mutex) *PheromonesMatrix {
+func NewPheromonesMatrix(n int, initial float64) *PheromonesMatrix {
 	data := make([]float64, n*n)
 	for i := range data {
 		data[i] = initial
 	}
-	return &PheromonesMatrix{mat.NewDense(n, n, data), mutex}
+	return &PheromonesMatrix{matrix: mat.NewDense(n, n, data)}
 }
 
 func (p *PheromonesMatrix) Set(i, j int, v float64) {
@@ -39,7 +39,9 @@ func (p *PheromonesMatrix) IntensifyAlong(path trip.Path, pheromone float64) {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
 	for _, step := range path.Steps {
-		p.AddAt(step.From, step.To, pheromone)
+		if step.Break == nil {
+			p.AddAt(step.From, step.To, pheromone)
+		}
 	}
 }
 
@@ -9,15 +9,45 @@ import (
 
 type Result struct {
 	path       trip.Path
+	days       []trip.Day
+	visited    int
+	missed     []Miss
+	empty      bool
 	time       time.Duration
 	distance   int64
 	priorities int
 	visitTimes VisitTimes
 }
 
-func NewResult(path trip.Path, dur time.Duration, dist int64, prio int, times VisitTimes) Result {
+// Miss is required place left out of the paths with the first reason it
+// couldn't be visited next.
+type Miss struct {
+	Index  int
+	Reason error
+}
+
+// NewResult returns result of paths of every day joined into one path,
+// missed are required places the paths don't visit.
+func NewResult(
+	paths []trip.Path,
+	days []trip.Day,
+	missed []Miss,
+	dur time.Duration,
+	dist int64,
+	prio int,
+	times VisitTimes,
+) Result {
+	visited := make(map[int]bool)
+	for _, p := range paths {
+		for _, i := range p.Path() {
+			visited[i] = true
+		}
+	}
 	return Result{
-		path:       path,
+		path:       trip.JoinPaths(paths...),
+		days:       days,
+		visited:    len(visited),
+		missed:     missed,
 		time:       dur,
 		distance:   dist,
 		priorities: prio,
@@ -28,6 +58,7 @@ func NewResult(path trip.Path, dur time.Duration, dist int64, prio int, times Vi
 func NewEmptyResult() Result {
 	return Result{
 		path:       trip.NewDummyPath(),
+		empty:      true,
 		time:       time.Duration(math.MaxInt64),
 		distance:   math.MaxInt64,
 		priorities: 0,
@@ -36,6 +67,12 @@ func NewEmptyResult() Result {
 }
 
 func (r *Result) BetterThan(o Result) bool {
+	if o.empty {
+		return !r.empty
+	}
+	if len(r.missed) != len(o.missed) {
+		return len(r.missed) < len(o.missed)
+	}
 	if r.priorities < o.priorities {
 		return false
 	}
@@ -44,10 +81,10 @@ func (r *Result) BetterThan(o Result) bool {
 	}
 
 	if r.priorities == o.priorities {
-		if r.path.Size() > o.path.Size() {
+		if r.visited > o.visited {
 			return true
 		}
-		if r.path.Size() == o.path.Size() && r.time < o.time {
+		if r.visited == o.visited && r.time < o.time {
 			return true
 		}
 	}
@@ -58,6 +95,16 @@ func (r *Result) Path() trip.Path {
 	return r.path
 }
 
+// Days returns paths of every active window of the trip.
+func (r *Result) Days() []trip.Day {
+	return r.days
+}
+
+// Missed returns required places that are not visited.
+func (r *Result) Missed() []Miss {
+	return r.missed
+}
+
 func (r *Result) Time() time.Duration {
 	return r.time
 }
@@ -5,32 +5,68 @@ import (
 	"errors"
 	"fmt"
 	"math"
-	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/planner/ants"
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/provider"
 	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/trip"
-	"googlemaps.github.io/maps"
 )
 
 const Iterations = 10000
 
+// DefaultMatrixConcurrency is number of matrix requests fetched at once if
+// planner is created with non-positive concurrency.
+const DefaultMatrixConcurrency = 4
+
+var ErrMatrixSize = errors.New("matrix response size does not match the request")
+
+// ErrRequiredMissed is returned if even the best path found doesn't visit
+// all required places and places with appointments.
+type ErrRequiredMissed struct {
+	Missed []Missed
+}
+
+// Missed is required place that couldn't be fitted into the trip.
+type Missed struct {
+	Place  int    `json:"place"`
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+func (err ErrRequiredMissed) Error() string {
+	var missed []string
+	for _, m := range err.Missed {
+		missed = append(missed, fmt.Sprintf("%s (%s)", m.Name, m.Reason))
+	}
+	return "required places can't be fitted into the trip: " + strings.Join(missed, ", ")
+}
+
 type Planner struct {
-	client *maps.Client
-	trip   *trip.Trip
-	ants   int
-	boost  float64
+	matrix      provider.MatrixFetcher
+	concurrency int
+	trip        *trip.Trip
+	ants        int
+	boost       float64
 }
 
-func NewPlanner(c *maps.Client, t *trip.Trip) *Planner {
+// NewPlanner returns Planner fetching travel matrices of the trip with up to
+// matrixConcurrency requests at once.
+func NewPlanner(m provider.MatrixFetcher, t *trip.Trip, matrixConcurrency int) *Planner {
+	if matrixConcurrency <= 0 {
+		matrixConcurrency = DefaultMatrixConcurrency
+	}
 	return &Planner{
-		client: c,
-		trip:   t,
+		matrix:      m,
+		concurrency: matrixConcurrency,
+		trip:        t,
 	}
 }
 
-func (planner *Planner) Evaluate() (err error) {
+// Evaluate fetches travel matrices and searches for the best path of the trip,
+// it stops and returns context error as soon as ctx is done.
+func (planner *Planner) Evaluate(ctx context.Context) (err error) {
 	var durations *ants.TimesMappedDurationsMatrix
 	var distances *ants.TimesMappedDistancesMatrix
 	var pheromones *ants.PheromonesMatrix
@@ -45,12 +81,12 @@ func (planner *Planner) Evaluate() (err error) {
 		}
 		planner.ants = int(math.Ceil(5.0 * math.Sqrt(float64(length))))
 		planner.boost = priorities / float64(length)
-		durations, distances, err = durationsAndDistances(planner.trip, planner.client)
+		durations, distances, err = durationsAndDistances(ctx, planner.trip, planner.matrix, planner.concurrency)
 		if err != nil {
 			return err
 		}
 		swarm = make([]*ants.Ant, planner.ants)
-		pheromones = ants.NewPheromonesMatrix(length, planner.boost, sync.Mutex{})
+		pheromones = ants.NewPheromonesMatrix(length, planner.boost)
 	}
 
 	var bestResult = ants.NewEmptyResult()
@@ -59,7 +95,11 @@ func (planner *Planner) Evaluate() (err error) {
 	for i := 0; i < planner.ants; i++ {
 		swarm[i] = ants.NewAnt(planner.trip, distances, durations, pheromones, resultChannel)
 	}
+	defer close(resultChannel)
 	for i := 0; i < Iterations; i++ {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
 		for i := 0; i < planner.ants; i++ {
 			go swarm[i].FindFood()
 		}
@@ -86,13 +126,23 @@ func (planner *Planner) Evaluate() (err error) {
 		}
 		wg.Wait()
 	}
-	close(resultChannel)
+
+	if missed := bestResult.Missed(); len(missed) > 0 {
+		return newErrRequiredMissed(planner.trip, missed)
+	}
 
 	for _, place := range planner.trip.Places {
 		place.Arrival = bestResult.VisitTimes().Arrivals[place.Index]
 		place.Departure = bestResult.VisitTimes().Departures[place.Index]
 	}
-	planner.trip.TripEnd = planner.trip.TripStart.Add(bestResult.Time())
+	if planner.trip.Windows != nil {
+		days := bestResult.Days()
+		planner.trip.Days = days
+		planner.trip.TripStart = days[0].Start
+		planner.trip.TripEnd = days[len(days)-1].End
+	} else {
+		planner.trip.TripEnd = planner.trip.TripStart.Add(bestResult.Time())
+	}
 	planner.trip.TotalDistance = bestResult.Distance()
 
 	path := bestResult.Path()
@@ -113,66 +163,127 @@ func (planner *Planner) Evaluate() (err error) {
 	return err
 }
 
-func durationsAndDistances(trip *trip.Trip, client *maps.Client) (
-	durations *ants.TimesMappedDurationsMatrix,
-	distances *ants.TimesMappedDistancesMatrix,
-	err error,
-) {
-	length := len(trip.Places)
-	currentTime := trip.TripStart
-	var checkedTimes []time.Time
+func newErrRequiredMissed(t *trip.Trip, missed []ants.Miss) (err ErrRequiredMissed) {
+	for _, m := range missed {
+		p := t.Places[m.Index]
+		reason := m.Reason.Error()
+		if ap := p.Appointment; ap != nil {
+			reason = fmt.Sprintf("appointment between %s and %s can't be honoured, %s",
+				ap.Start.Format(time.RFC3339), ap.End.Format(time.RFC3339), reason)
+		}
+		err.Missed = append(err.Missed, Missed{Place: p.Index, Name: p.Details.Name, Reason: reason})
+	}
+	return err
+}
+
+// SampleTimes returns departure times for which travel matrices of trip
+// between start and end are fetched, every 2 hours for trips up to 12 hours
+// long and every 4 hours for longer ones.
+func SampleTimes(start, end time.Time) (times []time.Time) {
 	var timeDelta time.Duration
-	if trip.TripEnd.Sub(currentTime).Hours() <= 12 {
+	if end.Sub(start).Hours() <= 12 {
 		timeDelta = time.Duration(2) * time.Hour
 	} else {
 		timeDelta = time.Duration(4) * time.Hour
 	}
-	for !currentTime.After(trip.TripEnd) {
-		checkedTimes = append(checkedTimes, currentTime)
-		currentTime = currentTime.Add(timeDelta)
+	for currentTime := start; !currentTime.After(end); currentTime = currentTime.Add(timeDelta) {
+		times = append(times, currentTime)
+	}
+	return times
+}
+
+// SampleWindows returns departure times sampled in every window.
+func SampleWindows(windows []trip.Window) (times []time.Time) {
+	for _, w := range windows {
+		times = append(times, SampleTimes(w.Start, w.End)...)
+	}
+	return times
+}
+
+// fits checks whether matrix has row of every origin of the request, each
+// with element of every destination.
+func fits(m provider.Matrix, r provider.MatrixRequest) bool {
+	if len(m.Rows) != len(r.Origins) {
+		return false
+	}
+	for _, row := range m.Rows {
+		if len(row) != len(r.Destinations) {
+			return false
+		}
 	}
+	return true
+}
+
+func durationsAndDistances(ctx context.Context, trip *trip.Trip, matrix provider.MatrixFetcher, concurrency int) (
+	durations *ants.TimesMappedDurationsMatrix,
+	distances *ants.TimesMappedDistancesMatrix,
+	err error,
+) {
+	length := len(trip.Places)
+	checkedTimes := SampleWindows(trip.ActiveWindows())
 	durations = ants.NewTravelTimeMatrix(length, checkedTimes)
 	distances = ants.NewDistanceMatrix(length, checkedTimes)
-	destinationAddresses := make([]string, length)
-	originAddresses := make([]string, length)
+	waypoints := make([]provider.Waypoint, length)
 	for _, place := range trip.Places {
-		destinationAddresses[place.Index] = place.Details.FormattedAddress
-		originAddresses[place.Index] = place.Details.FormattedAddress
+		waypoints[place.Index] = place.Waypoint()
 	}
+	var tiles []tile
 	for _, t := range checkedTimes {
-		r := &maps.DistanceMatrixRequest{
-			Origins:       originAddresses,
-			Destinations:  destinationAddresses,
-			DepartureTime: strconv.Itoa(int(t.Unix())),
-			Mode:          trip.TravelMode,
-		}
-		var resp *maps.DistanceMatrixResponse
-		resp, err := client.DistanceMatrix(context.Background(), r)
+		tiles = append(tiles, splitMatrix(waypoints, t, trip.TravelMode, matrix.MatrixLimits(trip.TravelMode))...)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan tileResult, len(tiles))
+	budget := make(chan struct{}, concurrency)
+	for _, tl := range tiles {
+		go func(tl tile) {
+			budget <- struct{}{}
+			defer func() { <-budget }()
+			if err := ctx.Err(); err != nil {
+				results <- tileResult{tile: tl, err: err}
+				return
+			}
+			resp, err := matrix.DistanceMatrix(ctx, tl.request)
+			results <- tileResult{tile: tl, matrix: resp, err: err}
+		}(tl)
+	}
+
+	for range tiles {
+		r := <-results
 		if err != nil {
-			return durations, distances, err
+			continue
+		}
+		if err = r.err; err != nil {
+			cancel()
+			continue
+		}
+		if !fits(r.matrix, r.request) {
+			err = ErrMatrixSize
+			cancel()
+			continue
 		}
-		for i, row := range resp.Rows {
-			for j, element := range row.Elements {
+		t := r.request.DepartureTime
+		for ri, row := range r.matrix.Rows {
+			for ci, element := range row {
+				i, j := r.origin+ri, r.destination+ci
 				if i != j {
-					if element.Status == "OK" {
-						if trip.TravelMode == maps.TravelModeDriving {
-							durations.Set(i, j, t, element.DurationInTraffic)
-						} else {
-							durations.Set(i, j, t, element.Duration)
-						}
-						distances.Set(i, j, t, int64(element.Distance.Meters))
-					} else {
-						return durations, distances, errors.New(fmt.Sprintf(
+					if element.OK {
+						durations.Set(i, j, t, element.Duration)
+						distances.Set(i, j, t, element.Distance)
+					} else if err == nil {
+						err = errors.New(fmt.Sprintf(
 							"could not get distances between %s and %s at %s",
-							originAddresses[i],
-							destinationAddresses[j],
+							waypoints[i],
+							waypoints[j],
 							t.String(),
 						))
+						cancel()
 					}
 				}
 			}
 		}
 	}
 
-	return durations, distances, nil
+	return durations, distances, err
 }
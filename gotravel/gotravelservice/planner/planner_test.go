@@ -0,0 +1,241 @@
+package planner
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/planner/ants"
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/provider"
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/trip"
+)
+
+// lineFetcher returns matrices of places on a line, travel between places
+// with latitudes i and j takes |i-j| minutes. Mangle alters every response.
+type lineFetcher struct {
+	limits provider.MatrixLimits
+	mangle func(m *provider.Matrix)
+}
+
+func (f lineFetcher) DistanceMatrix(_ context.Context, r provider.MatrixRequest) (provider.Matrix, error) {
+	var m provider.Matrix
+	for _, o := range r.Origins {
+		row := make([]provider.MatrixElement, len(r.Destinations))
+		for j, d := range r.Destinations {
+			minutes := o.LatLng.Lat - d.LatLng.Lat
+			if minutes < 0 {
+				minutes = -minutes
+			}
+			row[j] = provider.MatrixElement{OK: true, Duration: time.Duration(minutes) * time.Minute, Distance: int64(minutes)}
+		}
+		m.Rows = append(m.Rows, row)
+	}
+	if f.mangle != nil {
+		f.mangle(&m)
+	}
+	return m, nil
+}
+
+func (f lineFetcher) MatrixLimits(provider.TravelMode) provider.MatrixLimits {
+	return f.limits
+}
+
+func lineTrip(n int) *trip.Trip {
+	start := time.Date(2024, time.June, 4, 9, 0, 0, 0, time.UTC)
+	t := &trip.Trip{TripStart: start, TripEnd: start.Add(8 * time.Hour)}
+	for i := 0; i < n; i++ {
+		t.Places = append(t.Places, &trip.Place{
+			Index:   i,
+			Details: trip.PlaceDetails{Coordinates: provider.LatLng{Lat: float64(i)}},
+		})
+	}
+	return t
+}
+
+func TestDurationsAndDistances(t *testing.T) {
+	tests := []struct {
+		name    string
+		fetcher lineFetcher
+		err     error
+	}{
+		{
+			name:    "single request",
+			fetcher: lineFetcher{},
+		},
+		{
+			name:    "tiles",
+			fetcher: lineFetcher{limits: provider.MatrixLimits{MaxOrigins: 2, MaxDestinations: 2}},
+		},
+		{
+			name: "missing row",
+			fetcher: lineFetcher{mangle: func(m *provider.Matrix) {
+				m.Rows = m.Rows[1:]
+			}},
+			err: ErrMatrixSize,
+		},
+		{
+			name: "row too long",
+			fetcher: lineFetcher{mangle: func(m *provider.Matrix) {
+				m.Rows[0] = append(m.Rows[0], provider.MatrixElement{OK: true})
+			}},
+			err: ErrMatrixSize,
+		},
+		{
+			name: "row too short",
+			fetcher: lineFetcher{
+				limits: provider.MatrixLimits{MaxElements: 4},
+				mangle: func(m *provider.Matrix) {
+					m.Rows[0] = m.Rows[0][:len(m.Rows[0])-1]
+				},
+			},
+			err: ErrMatrixSize,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := lineTrip(5)
+			durations, distances, err := durationsAndDistances(context.Background(), tr, tt.fetcher, 2)
+			if err != tt.err {
+				t.Fatalf("durationsAndDistances error = %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			for i := 0; i < 5; i++ {
+				for j := 0; j < 5; j++ {
+					if i == j {
+						continue
+					}
+					want := j - i
+					if want < 0 {
+						want = -want
+					}
+					if d := durations.At(i, j, tr.TripStart); d != time.Duration(want)*time.Minute {
+						t.Errorf("duration %d-%d = %v, want %d minutes", i, j, d, want)
+					}
+					if d := distances.At(i, j, tr.TripStart); d != int64(want) {
+						t.Errorf("distance %d-%d = %d, want %d", i, j, d, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+// countingFetcher counts requests made to lineFetcher, call is made with
+// number of every request after it is answered.
+type countingFetcher struct {
+	lineFetcher
+	calls int32
+	call  func(n int32)
+}
+
+func (f *countingFetcher) DistanceMatrix(ctx context.Context, r provider.MatrixRequest) (provider.Matrix, error) {
+	n := atomic.AddInt32(&f.calls, 1)
+	if f.call != nil {
+		defer f.call(n)
+	}
+	return f.lineFetcher.DistanceMatrix(ctx, r)
+}
+
+func TestEvaluateCancelled(t *testing.T) {
+	// 5 places in tiles of 2 by 2 take 9 requests at each of 5 sampled times
+	limits := provider.MatrixLimits{MaxOrigins: 2, MaxDestinations: 2}
+	const tiles = 45
+
+	tests := []struct {
+		name  string
+		ctx   func() (context.Context, context.CancelFunc)
+		call  func(cancel context.CancelFunc) func(n int32)
+		calls int32
+		err   error
+	}{
+		{
+			name: "expired deadline",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+			},
+			err: context.DeadlineExceeded,
+		},
+		{
+			name: "cancelled while fetching matrices",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithCancel(context.Background())
+			},
+			call: func(cancel context.CancelFunc) func(n int32) {
+				return func(n int32) {
+					if n == 3 {
+						cancel()
+					}
+				}
+			},
+			calls: 3,
+			err:   context.Canceled,
+		},
+		{
+			name: "cancelled after matrices are fetched",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithCancel(context.Background())
+			},
+			call: func(cancel context.CancelFunc) func(n int32) {
+				return func(n int32) {
+					if n == tiles {
+						cancel()
+					}
+				}
+			},
+			calls: tiles,
+			err:   context.Canceled,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := tt.ctx()
+			defer cancel()
+			f := &countingFetcher{lineFetcher: lineFetcher{limits: limits}}
+			if tt.call != nil {
+				f.call = tt.call(cancel)
+			}
+
+			start := time.Now()
+			err := NewPlanner(f, lineTrip(5), 1).Evaluate(ctx)
+			if err != tt.err {
+				t.Errorf("Evaluate error = %v, want %v", err, tt.err)
+			}
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("Evaluate returned after %v", elapsed)
+			}
+			if calls := atomic.LoadInt32(&f.calls); calls != tt.calls {
+				t.Errorf("%d matrix requests made, want %d", calls, tt.calls)
+			}
+		})
+	}
+}
+
+func TestEvaluateRequiredMissed(t *testing.T) {
+	tr := lineTrip(4)
+	for _, p := range tr.Places {
+		p.StayDuration, p.Priority = 30, 1
+		p.Details.Name = fmt.Sprintf("place %d", p.Index)
+		p.Details.Location = time.UTC
+		p.Details.OpeningHoursPeriods = make(map[time.Weekday][]trip.OpeningHours, 7)
+		for d := time.Sunday; d <= time.Saturday; d++ {
+			p.Details.OpeningHoursPeriods[d] = []trip.OpeningHours{{Open: "0000", Close: "0000", CloseDay: 1}}
+		}
+	}
+	tr.Places[2].Required = true
+	tr.Places[2].Details.PermanentlyClosed = true
+
+	err := NewPlanner(lineFetcher{}, tr, 1).Evaluate(context.Background())
+	missed, ok := err.(ErrRequiredMissed)
+	if !ok {
+		t.Fatalf("Evaluate error = %v, want ErrRequiredMissed", err)
+	}
+	want := []Missed{{Place: 2, Name: "place 2", Reason: ants.ErrPlaceClosed.Error()}}
+	if !reflect.DeepEqual(missed.Missed, want) {
+		t.Errorf("missed %+v, want %+v", missed.Missed, want)
+	}
+}
@@ -0,0 +1,79 @@
+package planner
+
+import (
+	"time"
+
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/provider"
+)
+
+// tile is a matrix request for part of trip's travel matrix, with origins
+// and destinations starting at given indices of trip places.
+type tile struct {
+	origin      int
+	destination int
+	request     provider.MatrixRequest
+}
+
+type tileResult struct {
+	tile
+	matrix provider.Matrix
+	err    error
+}
+
+// splitMatrix splits matrix request between all waypoints into tiles that
+// do not exceed given limits.
+func splitMatrix(waypoints []provider.Waypoint, t time.Time, mode provider.TravelMode, limits provider.MatrixLimits) (
+	tiles []tile,
+) {
+	n := len(waypoints)
+	rows, cols := tileSize(n, limits)
+	for o := 0; o < n; o += rows {
+		for d := 0; d < n; d += cols {
+			tiles = append(tiles, tile{
+				origin:      o,
+				destination: d,
+				request: provider.MatrixRequest{
+					Origins:       waypoints[o:minInt(o+rows, n)],
+					Destinations:  waypoints[d:minInt(d+cols, n)],
+					DepartureTime: t,
+					Mode:          mode,
+				},
+			})
+		}
+	}
+	return tiles
+}
+
+// MatrixSize returns number of requests and their elements needed to fetch
+// travel matrix between n waypoints at single departure time within limits.
+func MatrixSize(n int, limits provider.MatrixLimits) (requests, elements int) {
+	rows, cols := tileSize(n, limits)
+	requests = ((n + rows - 1) / rows) * ((n + cols - 1) / cols)
+	return requests, n * n
+}
+
+// tileSize returns the largest numbers of origins and destinations of n by n
+// matrix tile within limits, preferring tiles of full rows.
+func tileSize(n int, limits provider.MatrixLimits) (rows, cols int) {
+	rows, cols = n, n
+	if limits.MaxOrigins > 0 && rows > limits.MaxOrigins {
+		rows = limits.MaxOrigins
+	}
+	if limits.MaxDestinations > 0 && cols > limits.MaxDestinations {
+		cols = limits.MaxDestinations
+	}
+	if limits.MaxElements > 0 && rows*cols > limits.MaxElements {
+		if cols > limits.MaxElements {
+			cols = limits.MaxElements
+		}
+		rows = limits.MaxElements / cols
+	}
+	return rows, cols
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
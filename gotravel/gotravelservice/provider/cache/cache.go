@@ -0,0 +1,261 @@
+// Package cache implements provider caching responses of another provider in
+// BoltDB file, so that places and travel matrices survive server restarts.
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/provider"
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/provider/google"
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/usage"
+)
+
+// TTLs are times for which responses of each kind are served from cache.
+type TTLs struct {
+	Places  time.Duration
+	Details time.Duration
+	Matrix  time.Duration
+}
+
+// DefaultTTLs keep place IDs for 30 days, details that include opening hours
+// for a day and travel matrices for a week.
+var DefaultTTLs = TTLs{
+	Places:  30 * 24 * time.Hour,
+	Details: 24 * time.Hour,
+	Matrix:  7 * 24 * time.Hour,
+}
+
+var (
+	bucketPlaces  = []byte("places")
+	bucketDetails = []byte("details")
+	bucketMatrix  = []byte("matrix")
+)
+
+type entry struct {
+	Expires     time.Time       `json:"expires"`
+	Response    json.RawMessage `json:"response,omitempty"`
+	ZeroResults bool            `json:"zeroResults,omitempty"`
+}
+
+// Store is BoltDB database holding cached responses in buckets per kind.
+type Store struct {
+	db   *bolt.DB
+	ttls TTLs
+}
+
+// Open opens or creates database file at path and removes expired entries.
+func Open(path string, ttls TTLs) (*Store, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	s := &Store{db: db, ttls: ttls}
+	if err = s.purge(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// purge creates missing buckets and deletes expired entries.
+func (s *Store) purge() error {
+	now := time.Now()
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketPlaces, bucketDetails, bucketMatrix} {
+			b, err := tx.CreateBucketIfNotExists(name)
+			if err != nil {
+				return err
+			}
+			var expired [][]byte
+			if err = b.ForEach(func(k, v []byte) error {
+				var e entry
+				if json.Unmarshal(v, &e) != nil || e.Expires.Before(now) {
+					expired = append(expired, k)
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+			for _, k := range expired {
+				if err = b.Delete(k); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// get loads cached response to given key into response, it reports whether
+// valid entry was found.
+func (s *Store) get(bucket []byte, key string, response interface{}) (found bool, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(bucket).Get(hash(key))
+		if v == nil {
+			return nil
+		}
+		var e entry
+		if err := json.Unmarshal(v, &e); err != nil || e.Expires.Before(time.Now()) {
+			return nil
+		}
+		found = true
+		if e.ZeroResults {
+			return provider.ErrZeroResults
+		}
+		return json.Unmarshal(e.Response, response)
+	})
+	return found, err
+}
+
+func (s *Store) put(bucket []byte, key string, ttl time.Duration, response interface{}, respErr error) error {
+	e := entry{Expires: time.Now().Add(ttl)}
+	if respErr == provider.ErrZeroResults {
+		e.ZeroResults = true
+	} else {
+		var err error
+		if e.Response, err = json.Marshal(response); err != nil {
+			return err
+		}
+	}
+	v, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucket).Put(hash(key), v)
+	})
+}
+
+func hash(key string) []byte {
+	sum := sha256.Sum256([]byte(key))
+	return sum[:]
+}
+
+// Provider serves responses of the next provider from Store and caches the
+// new ones. Responses are shared by all API keys, keys are never stored, and
+// kept apart by backends answering the calls, so that switching backends
+// doesn't serve responses of the previous one. Served responses of Google
+// are counted as cached in usage.Counter of call context, other backends are
+// not billed.
+type Provider struct {
+	next     provider.Provider
+	store    *Store
+	backends provider.Backends
+}
+
+func New(next provider.Provider, store *Store, backends provider.Backends) *Provider {
+	return &Provider{next: next, store: store, backends: backends}
+}
+
+// NewFactory returns provider.Factory wrapping providers created by next
+// factory, which are answered by given backends, with Provider using given
+// store.
+func NewFactory(next provider.Factory, store *Store, backends provider.Backends) provider.Factory {
+	return func(apiKey string) (provider.Provider, error) {
+		p, err := next(apiKey)
+		if err != nil {
+			return nil, err
+		}
+		return New(p, store, backends), nil
+	}
+}
+
+func (p *Provider) PlaceID(ctx context.Context, query string) (placeID string, err error) {
+	key := p.backends.Places + "|query:" + normalize(query)
+	if found, err := p.store.get(bucketPlaces, key, &placeID); found {
+		cached(ctx, p.backends.Places, usage.Autocomplete, 1)
+		return placeID, err
+	}
+	placeID, err = p.next.PlaceID(ctx, query)
+	return placeID, p.save(bucketPlaces, key, p.store.ttls.Places, placeID, err)
+}
+
+func (p *Provider) PlaceIDAt(ctx context.Context, ll provider.LatLng) (placeID string, err error) {
+	key := p.backends.Places + "|latlng:" + ll.String()
+	if found, err := p.store.get(bucketPlaces, key, &placeID); found {
+		cached(ctx, p.backends.Places, usage.Geocoding, 1)
+		return placeID, err
+	}
+	placeID, err = p.next.PlaceIDAt(ctx, ll)
+	return placeID, p.save(bucketPlaces, key, p.store.ttls.Places, placeID, err)
+}
+
+func (p *Provider) PlaceDetails(ctx context.Context, placeID string, language string) (d provider.Details, err error) {
+	key := p.backends.Places + "|" + placeID + "|" + normalize(language)
+	if found, err := p.store.get(bucketDetails, key, &d); found {
+		cached(ctx, p.backends.Places, usage.Details, 1)
+		return d, err
+	}
+	d, err = p.next.PlaceDetails(ctx, placeID, language)
+	return d, p.save(bucketDetails, key, p.store.ttls.Details, d, err)
+}
+
+func (p *Provider) DistanceMatrix(ctx context.Context, r provider.MatrixRequest) (m provider.Matrix, err error) {
+	backend := p.backends.MatrixIn(r.Mode)
+	key := backend + "|" + matrixKey(r)
+	if found, err := p.store.get(bucketMatrix, key, &m); found {
+		cached(ctx, backend, usage.Matrix, 1)
+		cached(ctx, backend, usage.MatrixElements, len(r.Origins)*len(r.Destinations))
+		return m, err
+	}
+	m, err = p.next.DistanceMatrix(ctx, r)
+	return m, p.save(bucketMatrix, key, p.store.ttls.Matrix, m, err)
+}
+
+func (p *Provider) MatrixLimits(mode provider.TravelMode) provider.MatrixLimits {
+	return p.next.MatrixLimits(mode)
+}
+
+// cached counts n calls of kind k served from cache if backend answering
+// them is Google.
+func cached(ctx context.Context, backend string, k usage.Kind, n int) {
+	if backend == google.Backend {
+		usage.FromContext(ctx).Cached(k, n)
+	}
+}
+
+// save caches successful and zero results responses and returns response
+// error, failing to save is not an error of the call.
+func (p *Provider) save(bucket []byte, key string, ttl time.Duration, response interface{}, err error) error {
+	if err == nil || err == provider.ErrZeroResults {
+		p.store.put(bucket, key, ttl, response, err)
+	}
+	return err
+}
+
+// normalize makes queries differing only in case and whitespace equal.
+func normalize(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
+
+// matrixKey identifies matrix request by travel mode, departure time in UTC
+// to a minute and normalized waypoints.
+func matrixKey(r provider.MatrixRequest) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s|%s", r.Mode, r.DepartureTime.UTC().Truncate(time.Minute).Format(time.RFC3339))
+	for _, ws := range [][]provider.Waypoint{r.Origins, r.Destinations} {
+		b.WriteString("|")
+		for i, w := range ws {
+			if i > 0 {
+				b.WriteString(";")
+			}
+			if w.LatLng != nil {
+				b.WriteString(w.LatLng.String())
+			}
+			b.WriteString("@")
+			b.WriteString(normalize(w.Address))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,130 @@
+package cache
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/provider"
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/provider/google"
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/usage"
+)
+
+// named answers every call with its name and counts calls made.
+type named struct {
+	name  string
+	calls int
+}
+
+func (n *named) PlaceID(context.Context, string) (string, error) {
+	n.calls++
+	return n.name, nil
+}
+
+func (n *named) PlaceIDAt(context.Context, provider.LatLng) (string, error) {
+	n.calls++
+	return n.name, nil
+}
+
+func (n *named) PlaceDetails(context.Context, string, string) (provider.Details, error) {
+	n.calls++
+	return provider.Details{Name: n.name}, nil
+}
+
+func (n *named) DistanceMatrix(_ context.Context, r provider.MatrixRequest) (provider.Matrix, error) {
+	n.calls++
+	return provider.Matrix{Rows: [][]provider.MatrixElement{{{OK: true, Distance: int64(len(n.name))}}}}, nil
+}
+
+func (n *named) MatrixLimits(provider.TravelMode) provider.MatrixLimits {
+	return provider.MatrixLimits{}
+}
+
+func TestProviderKeepsBackendsApart(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "cache.db"), DefaultTTLs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	ll := provider.LatLng{Lat: 51.1, Lng: 17.0}
+	request := provider.MatrixRequest{
+		Origins:       []provider.Waypoint{{LatLng: &ll}},
+		Destinations:  []provider.Waypoint{{LatLng: &ll}},
+		DepartureTime: time.Date(2024, time.June, 4, 9, 0, 0, 0, time.UTC),
+		Mode:          provider.TravelModeWalking,
+	}
+	call := func(p *Provider) (id string, details string, distance int64) {
+		id, _ = p.PlaceID(ctx, "Hydropolis, Wrocław")
+		d, _ := p.PlaceDetails(ctx, "place", "pl")
+		m, _ := p.DistanceMatrix(ctx, request)
+		return id, d.Name, m.Rows[0][0].Distance
+	}
+
+	google := &named{name: "google"}
+	backends := provider.Backends{Places: "google", Matrix: "google"}
+	call(New(google, store, backends))
+	if id, details, distance := call(New(google, store, backends)); id != "google" || details != "google" || distance != 6 {
+		t.Errorf("cached responses %q, %q, %d, want google ones", id, details, distance)
+	}
+	if google.calls != 3 {
+		t.Errorf("%d calls made to google, want 3", google.calls)
+	}
+
+	other := &named{name: "osm"}
+	backends.Places = "nominatim http://localhost:8080"
+	backends.Serve(provider.TravelModeWalking, "osrm http://localhost:5000")
+	if id, details, distance := call(New(other, store, backends)); id != "osm" || details != "osm" || distance != 3 {
+		t.Errorf("responses %q, %q, %d after switching backends, want osm ones", id, details, distance)
+	}
+	if other.calls != 3 {
+		t.Errorf("%d calls made to new backends, want 3", other.calls)
+	}
+}
+
+func TestProviderCountsGoogleHits(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "cache.db"), DefaultTTLs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	ll := provider.LatLng{Lat: 51.1, Lng: 17.0}
+	request := func(mode provider.TravelMode) provider.MatrixRequest {
+		return provider.MatrixRequest{
+			Origins:       []provider.Waypoint{{LatLng: &ll}, {Address: "Rynek, Wrocław"}},
+			Destinations:  []provider.Waypoint{{LatLng: &ll}},
+			DepartureTime: time.Date(2024, time.June, 4, 9, 0, 0, 0, time.UTC),
+			Mode:          mode,
+		}
+	}
+	// places and walking are answered by OSM, driving by Google
+	backends := provider.Backends{Places: "nominatim http://localhost:8080", Matrix: google.Backend}
+	backends.Serve(provider.TravelModeWalking, "osrm http://localhost:5000")
+	call := func(p *Provider) usage.Usage {
+		ctx, counter := usage.NewContext(context.Background())
+		p.PlaceID(ctx, "Hydropolis, Wrocław")
+		p.PlaceIDAt(ctx, ll)
+		p.PlaceDetails(ctx, "place", "pl")
+		p.DistanceMatrix(ctx, request(provider.TravelModeWalking))
+		p.DistanceMatrix(ctx, request(provider.TravelModeDriving))
+		return counter.Usage()
+	}
+
+	next := &named{name: "osm"}
+	if u := call(New(next, store, backends)); u != (usage.Usage{}) {
+		t.Errorf("usage of calls to next provider = %+v, want none", u)
+	}
+	want := usage.Usage{
+		Matrix:         usage.Count{Cached: 1},
+		MatrixElements: usage.Count{Cached: 2},
+	}
+	if u := call(New(next, store, backends)); u != want {
+		t.Errorf("usage of cached calls = %+v, want only Google matrix %+v", u, want)
+	}
+	if next.calls != 5 {
+		t.Errorf("%d calls made to next provider, want 5", next.calls)
+	}
+}
@@ -0,0 +1,219 @@
+package google
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/provider"
+	"googlemaps.github.io/maps"
+)
+
+// Backend names Google Maps in provider.Backends.
+const Backend = "google"
+
+// Provider is provider.Provider backed by Google Maps Places and Distance
+// Matrix APIs.
+type Provider struct {
+	client *maps.Client
+}
+
+func New(c *maps.Client) *Provider {
+	return &Provider{client: c}
+}
+
+// NewFactory returns provider.Factory creating Google Maps clients that use
+// given HTTP client for API requests.
+func NewFactory(httpClient *http.Client) provider.Factory {
+	client := *httpClient
+	client.Transport = statusTransport{client.Transport}
+	return func(apiKey string) (provider.Provider, error) {
+		if apiKey == "" {
+			return nil, provider.ErrAPIKeyEmpty
+		}
+		c, err := maps.NewClient(maps.WithAPIKey(apiKey), maps.WithHTTPClient(&client))
+		if err != nil {
+			return nil, err
+		}
+		return New(c), nil
+	}
+}
+
+func (p *Provider) PlaceID(ctx context.Context, query string) (string, error) {
+	r := &maps.PlaceAutocompleteRequest{
+		Input: query,
+		Types: maps.AutocompletePlaceTypeEstablishment,
+	}
+	resp, err := p.client.PlaceAutocomplete(ctx, r)
+	if err != nil {
+		if strings.Contains(err.Error(), "ZERO_RESULTS") {
+			return "", provider.ErrZeroResults
+		}
+		return "", providerError(err)
+	}
+	if len(resp.Predictions) == 0 {
+		return "", provider.ErrZeroResults
+	}
+	return resp.Predictions[0].PlaceID, nil
+}
+
+func (p *Provider) PlaceIDAt(ctx context.Context, ll provider.LatLng) (string, error) {
+	r := &maps.GeocodingRequest{
+		LatLng: &maps.LatLng{Lat: ll.Lat, Lng: ll.Lng},
+	}
+	resp, err := p.client.ReverseGeocode(ctx, r)
+	if err != nil {
+		return "", providerError(err)
+	}
+	if len(resp) == 0 {
+		return "", provider.ErrZeroResults
+	}
+	return resp[0].PlaceID, nil
+}
+
+func (p *Provider) PlaceDetails(ctx context.Context, placeID string, language string) (provider.Details, error) {
+	r := &maps.PlaceDetailsRequest{
+		PlaceID:  placeID,
+		Language: language,
+	}
+	resp, err := p.client.PlaceDetails(ctx, r)
+	if err != nil {
+		return provider.Details{}, providerError(err)
+	}
+
+	d := provider.Details{
+		Name:              resp.Name,
+		FormattedAddress:  resp.FormattedAddress,
+		PermanentlyClosed: resp.PermanentlyClosed,
+		LatLng: provider.LatLng{
+			Lat: resp.Geometry.Location.Lat,
+			Lng: resp.Geometry.Location.Lng,
+		},
+		UTCOffset: resp.UTCOffset,
+	}
+	if resp.OpeningHours != nil {
+		d.Periods = make([]provider.Period, 0, len(resp.OpeningHours.Periods))
+		for _, o := range resp.OpeningHours.Periods {
+			d.Periods = append(d.Periods, provider.Period{
+				Open:  provider.PeriodTime{Day: o.Open.Day, Time: o.Open.Time},
+				Close: provider.PeriodTime{Day: o.Close.Day, Time: o.Close.Time},
+			})
+		}
+	}
+	return d, nil
+}
+
+func (p *Provider) DistanceMatrix(ctx context.Context, r provider.MatrixRequest) (provider.Matrix, error) {
+	req := &maps.DistanceMatrixRequest{
+		Origins:       waypoints(r.Origins),
+		Destinations:  waypoints(r.Destinations),
+		DepartureTime: strconv.Itoa(int(r.DepartureTime.Unix())),
+		Mode:          maps.Mode(r.Mode),
+	}
+	resp, err := p.client.DistanceMatrix(ctx, req)
+	if err != nil {
+		return provider.Matrix{}, providerError(err)
+	}
+
+	m := provider.Matrix{Rows: make([][]provider.MatrixElement, len(resp.Rows))}
+	for i, row := range resp.Rows {
+		m.Rows[i] = make([]provider.MatrixElement, len(row.Elements))
+		for j, element := range row.Elements {
+			if element.Status != "OK" {
+				continue
+			}
+			m.Rows[i][j] = provider.MatrixElement{
+				OK:       true,
+				Duration: element.Duration,
+				Distance: int64(element.Distance.Meters),
+			}
+			if r.Mode == provider.TravelModeDriving {
+				m.Rows[i][j].Duration = element.DurationInTraffic
+			}
+		}
+	}
+	return m, nil
+}
+
+// Limits are limits of Distance Matrix API
+// (https://developers.google.com/maps/documentation/distance-matrix/usage-and-billing#other-usage-limits).
+var Limits = provider.MatrixLimits{
+	MaxOrigins:      25,
+	MaxDestinations: 25,
+	MaxElements:     100,
+}
+
+func (p *Provider) MatrixLimits(provider.TravelMode) provider.MatrixLimits {
+	return Limits
+}
+
+func waypoints(ws []provider.Waypoint) []string {
+	s := make([]string, len(ws))
+	for i, w := range ws {
+		s[i] = w.String()
+	}
+	return s
+}
+
+// providerError removes API key from URLs included in err and wraps errors
+// of exceeded query limits, server and network timeout errors with
+// provider.ErrTemporary.
+func providerError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		if u, parseErr := url.Parse(urlErr.URL); parseErr == nil {
+			q := u.Query()
+			q.Del("key")
+			q.Del("signature")
+			u.RawQuery = q.Encode()
+			urlErr.URL = u.String()
+		} else {
+			urlErr.URL = "[redacted]"
+		}
+	}
+
+	var netErr net.Error
+	switch {
+	case strings.Contains(err.Error(), "OVER_QUERY_LIMIT"),
+		strings.Contains(err.Error(), "UNKNOWN_ERROR"),
+		errors.As(err, &netErr) && netErr.Timeout(),
+		errors.As(err, &errServerStatus{}):
+		return provider.ErrTemporary{Err: err}
+	}
+	return err
+}
+
+type errServerStatus struct {
+	Status string
+}
+
+func (err errServerStatus) Error() string {
+	return fmt.Sprintf("maps: server responded with %s", err.Status)
+}
+
+// statusTransport fails requests that got server error or too many requests
+// responses, maps.Client would otherwise fail to decode their body.
+type statusTransport struct {
+	next http.RoundTripper
+}
+
+func (t statusTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	next := t.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	resp, err := next.RoundTrip(r)
+	if err != nil {
+		return resp, err
+	}
+	if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+		resp.Body.Close()
+		return nil, errServerStatus{resp.Status}
+	}
+	return resp, nil
+}
@@ -0,0 +1,371 @@
+package gtfs
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrNoConnections = errors.New("GTFS feed contains no timed connections")
+
+type ErrBadFeed struct {
+	File string
+	Line int
+	Err  error
+}
+
+func (err ErrBadFeed) Error() string {
+	return fmt.Sprintf("bad GTFS file %s at line %d: %v", err.File, err.Line, err.Err)
+}
+
+// Feed is GTFS schedule loaded into memory as timetable of connections
+// between consecutive stops of every trip, sorted by departure time.
+type Feed struct {
+	location    *time.Location
+	stops       []stop
+	services    []service
+	trips       []int
+	connections []connection
+	footpaths   [][]footpath
+	grid        grid
+}
+
+type stop struct {
+	lat, lng float64
+}
+
+type service struct {
+	weekdays   [7]bool
+	start, end int
+	added      map[int]bool
+	removed    map[int]bool
+}
+
+// activeOn reports whether service runs on date given in yyyymmdd format.
+func (s *service) activeOn(date int, weekday time.Weekday) bool {
+	if s.removed[date] {
+		return false
+	}
+	if s.added[date] {
+		return true
+	}
+	return s.weekdays[weekday] && date >= s.start && date <= s.end
+}
+
+// connection is a ride of a trip between two consecutive stops, times are
+// seconds since midnight of trip's service day and can exceed 24 hours.
+type connection struct {
+	from, to           int32
+	departure, arrival int32
+	trip               int32
+}
+
+type footpath struct {
+	to       int
+	duration int32
+}
+
+// Load reads GTFS feed from directory containing agency.txt, stops.txt,
+// trips.txt, stop_times.txt and at least one of calendar.txt and
+// calendar_dates.txt files.
+func Load(dir string) (*Feed, error) {
+	f := &Feed{location: time.UTC}
+
+	if err := readFile(dir, "agency.txt", true, func(r row) error {
+		loc, err := time.LoadLocation(r.get("agency_timezone"))
+		if err != nil {
+			return err
+		}
+		f.location = loc
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	stopIndex := make(map[string]int)
+	if err := readFile(dir, "stops.txt", true, func(r row) error {
+		lat, err := strconv.ParseFloat(r.get("stop_lat"), 64)
+		if err != nil {
+			return err
+		}
+		lng, err := strconv.ParseFloat(r.get("stop_lon"), 64)
+		if err != nil {
+			return err
+		}
+		stopIndex[r.get("stop_id")] = len(f.stops)
+		f.stops = append(f.stops, stop{lat, lng})
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	serviceIndex := make(map[string]int)
+	serviceFor := func(id string) int {
+		i, ok := serviceIndex[id]
+		if !ok {
+			i = len(f.services)
+			serviceIndex[id] = i
+			f.services = append(f.services, service{
+				added:   make(map[int]bool),
+				removed: make(map[int]bool),
+			})
+		}
+		return i
+	}
+	calendar, err := readFileIfExists(dir, "calendar.txt", func(r row) (err error) {
+		s := &f.services[serviceFor(r.get("service_id"))]
+		days := []string{"sunday", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday"}
+		for i, day := range days {
+			s.weekdays[i] = r.get(day) == "1"
+		}
+		if s.start, err = strconv.Atoi(r.get("start_date")); err != nil {
+			return err
+		}
+		s.end, err = strconv.Atoi(r.get("end_date"))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	calendarDates, err := readFileIfExists(dir, "calendar_dates.txt", func(r row) error {
+		s := &f.services[serviceFor(r.get("service_id"))]
+		date, err := strconv.Atoi(r.get("date"))
+		if err != nil {
+			return err
+		}
+		switch r.get("exception_type") {
+		case "1":
+			s.added[date] = true
+		case "2":
+			s.removed[date] = true
+		default:
+			return fmt.Errorf("unknown exception_type %q", r.get("exception_type"))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !calendar && !calendarDates {
+		return nil, ErrBadFeed{File: "calendar.txt", Err: os.ErrNotExist}
+	}
+
+	tripIndex := make(map[string]int)
+	if err := readFile(dir, "trips.txt", true, func(r row) error {
+		tripIndex[r.get("trip_id")] = len(f.trips)
+		f.trips = append(f.trips, serviceFor(r.get("service_id")))
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	type stopTime struct {
+		sequence           int
+		stop               int
+		arrival, departure int
+	}
+	tripStopTimes := make([][]stopTime, len(f.trips))
+	if err := readFile(dir, "stop_times.txt", true, func(r row) error {
+		t, ok := tripIndex[r.get("trip_id")]
+		if !ok {
+			return fmt.Errorf("unknown trip_id %q", r.get("trip_id"))
+		}
+		s, ok := stopIndex[r.get("stop_id")]
+		if !ok {
+			return fmt.Errorf("unknown stop_id %q", r.get("stop_id"))
+		}
+		seq, err := strconv.Atoi(r.get("stop_sequence"))
+		if err != nil {
+			return err
+		}
+		arr, dep := r.get("arrival_time"), r.get("departure_time")
+		if arr == "" && dep == "" {
+			// untimed stops are skipped, connection spans to the next timed stop
+			return nil
+		} else if arr == "" {
+			arr = dep
+		} else if dep == "" {
+			dep = arr
+		}
+		st := stopTime{sequence: seq, stop: s}
+		if st.arrival, err = parseTime(arr); err != nil {
+			return err
+		}
+		if st.departure, err = parseTime(dep); err != nil {
+			return err
+		}
+		tripStopTimes[t] = append(tripStopTimes[t], st)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	for t, sts := range tripStopTimes {
+		sort.Slice(sts, func(i, j int) bool { return sts[i].sequence < sts[j].sequence })
+		for i := 1; i < len(sts); i++ {
+			f.connections = append(f.connections, connection{
+				from:      int32(sts[i-1].stop),
+				to:        int32(sts[i].stop),
+				departure: int32(sts[i-1].departure),
+				arrival:   int32(sts[i].arrival),
+				trip:      int32(t),
+			})
+		}
+	}
+	if len(f.connections) == 0 {
+		return nil, ErrNoConnections
+	}
+	sort.Slice(f.connections, func(i, j int) bool {
+		return f.connections[i].departure < f.connections[j].departure
+	})
+
+	f.grid = newGrid(f.stops)
+	f.footpaths = make([][]footpath, len(f.stops))
+	for i, s := range f.stops {
+		for _, n := range f.grid.nearby(s.lat, s.lng, TransferRadius) {
+			if n.stop != i {
+				f.footpaths[i] = append(f.footpaths[i], footpath{n.stop, walkingSeconds(n.distance)})
+			}
+		}
+	}
+	return f, nil
+}
+
+// parseTime parses GTFS time in HH:MM:SS format into seconds since midnight.
+func parseTime(s string) (int, error) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("time %q not in HH:MM:SS format", s)
+	}
+	var secs int
+	for _, p := range parts {
+		v, err := strconv.Atoi(p)
+		if err != nil {
+			return 0, fmt.Errorf("time %q not in HH:MM:SS format", s)
+		}
+		secs = secs*60 + v
+	}
+	return secs, nil
+}
+
+type row struct {
+	columns map[string]int
+	record  []string
+}
+
+func (r row) get(column string) string {
+	if i, ok := r.columns[column]; ok && i < len(r.record) {
+		return strings.TrimSpace(r.record[i])
+	}
+	return ""
+}
+
+func readFileIfExists(dir, name string, fn func(row) error) (bool, error) {
+	err := readFile(dir, name, false, fn)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func readFile(dir, name string, required bool, fn func(row) error) error {
+	file, err := os.Open(filepath.Join(dir, name))
+	if err != nil {
+		if !required {
+			return err
+		}
+		return ErrBadFeed{File: name, Err: err}
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if err != nil {
+		return ErrBadFeed{File: name, Line: 1, Err: err}
+	}
+	r := row{columns: make(map[string]int, len(header))}
+	for i, column := range header {
+		r.columns[strings.TrimSpace(strings.TrimPrefix(column, "\ufeff"))] = i
+	}
+	for line := 2; ; line++ {
+		r.record, err = reader.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return ErrBadFeed{File: name, Line: line, Err: err}
+		}
+		if err = fn(r); err != nil {
+			return ErrBadFeed{File: name, Line: line, Err: err}
+		}
+	}
+}
+
+const earthRadius = 6371000.0
+
+// haversine returns great-circle distance between two points in meters.
+func haversine(lat1, lng1, lat2, lng2 float64) float64 {
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLng := (lng2 - lng1) * rad
+	a := math.Pow(math.Sin(dLat/2), 2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Pow(math.Sin(dLng/2), 2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
+
+func walkingSeconds(distance float64) int32 {
+	return int32(math.Ceil(distance * WalkingDetour / WalkingSpeed))
+}
+
+// grid is a spatial index of stops bucketed into cells of gridCell degrees.
+type grid struct {
+	stops []stop
+	cells map[[2]int][]int
+}
+
+const gridCell = 0.01
+
+type neighbour struct {
+	stop     int
+	distance float64
+}
+
+func newGrid(stops []stop) grid {
+	g := grid{stops: stops, cells: make(map[[2]int][]int)}
+	for i, s := range stops {
+		c := cell(s.lat, s.lng)
+		g.cells[c] = append(g.cells[c], i)
+	}
+	return g
+}
+
+func cell(lat, lng float64) [2]int {
+	return [2]int{int(math.Floor(lat / gridCell)), int(math.Floor(lng / gridCell))}
+}
+
+// nearby returns stops within radius meters from given point.
+func (g grid) nearby(lat, lng, radius float64) (ns []neighbour) {
+	dLat := radius / (earthRadius * math.Pi / 180)
+	dLng := dLat / math.Max(math.Cos(lat*math.Pi/180), 0.01)
+	lo, hi := cell(lat-dLat, lng-dLng), cell(lat+dLat, lng+dLng)
+	for x := lo[0]; x <= hi[0]; x++ {
+		for y := lo[1]; y <= hi[1]; y++ {
+			for _, i := range g.cells[[2]int{x, y}] {
+				s := g.stops[i]
+				if d := haversine(lat, lng, s.lat, s.lng); d <= radius {
+					ns = append(ns, neighbour{i, d})
+				}
+			}
+		}
+	}
+	return ns
+}
@@ -0,0 +1,219 @@
+// Package gtfs implements provider.MatrixFetcher computing public transit
+// travel times offline from GTFS feed with connection scan algorithm.
+package gtfs
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/provider"
+)
+
+var ErrNoCoordinates = errors.New("GTFS routing requires coordinates of every waypoint")
+
+var (
+	// WalkingSpeed in meters per second used for access, egress and
+	// transfer legs as well as for walking the whole way.
+	WalkingSpeed = 1.3
+	// WalkingDetour is ratio of walked to straight line distance.
+	WalkingDetour = 1.3
+	// AccessRadius is maximal distance in meters walked to the first or from
+	// the last stop of the journey.
+	AccessRadius = 1000.0
+	// TransferRadius is maximal distance in meters walked between stops.
+	TransferRadius = 400.0
+	// MaxJourney limits time searched for connections after departure.
+	MaxJourney = 6 * time.Hour
+)
+
+// MatrixFetcher fetches transit matrices from GTFS feed and passes requests
+// in other travel modes to the next fetcher.
+type MatrixFetcher struct {
+	feed *Feed
+	next provider.MatrixFetcher
+}
+
+func NewMatrixFetcher(feed *Feed, next provider.MatrixFetcher) *MatrixFetcher {
+	return &MatrixFetcher{feed: feed, next: next}
+}
+
+// NewFactory returns provider.Factory replacing matrix fetcher of providers
+// created by next factory with MatrixFetcher using given feed.
+func NewFactory(next provider.Factory, feed *Feed) provider.Factory {
+	return func(apiKey string) (provider.Provider, error) {
+		p, err := next(apiKey)
+		if err != nil {
+			return nil, err
+		}
+		return provider.Composite{
+			PlaceResolver:  p,
+			DetailsFetcher: p,
+			MatrixFetcher:  NewMatrixFetcher(feed, p),
+		}, nil
+	}
+}
+
+// DistanceMatrix returns transit travel times departing at request's
+// departure time, distances are straight line distances between waypoints.
+func (f *MatrixFetcher) DistanceMatrix(ctx context.Context, r provider.MatrixRequest) (provider.Matrix, error) {
+	if r.Mode != provider.TravelModeTransit {
+		return f.next.DistanceMatrix(ctx, r)
+	}
+
+	destinations := make([]provider.LatLng, len(r.Destinations))
+	for j, w := range r.Destinations {
+		if w.LatLng == nil {
+			return provider.Matrix{}, ErrNoCoordinates
+		}
+		destinations[j] = *w.LatLng
+	}
+
+	m := provider.Matrix{Rows: make([][]provider.MatrixElement, len(r.Origins))}
+	for i, w := range r.Origins {
+		if err := ctx.Err(); err != nil {
+			return provider.Matrix{}, err
+		}
+		if w.LatLng == nil {
+			return provider.Matrix{}, ErrNoCoordinates
+		}
+		durations := f.feed.TravelTimes(*w.LatLng, r.DepartureTime, destinations)
+		m.Rows[i] = make([]provider.MatrixElement, len(destinations))
+		for j, d := range destinations {
+			m.Rows[i][j] = provider.MatrixElement{
+				OK:       true,
+				Duration: durations[j],
+				Distance: int64(haversine(w.LatLng.Lat, w.LatLng.Lng, d.Lat, d.Lng)),
+			}
+		}
+	}
+	return m, nil
+}
+
+func (f *MatrixFetcher) MatrixLimits(mode provider.TravelMode) provider.MatrixLimits {
+	if mode != provider.TravelModeTransit {
+		return f.next.MatrixLimits(mode)
+	}
+	return provider.MatrixLimits{}
+}
+
+type egress struct {
+	destination int
+	duration    int32
+}
+
+// TravelTimes returns the earliest arrival travel times from origin departing
+// at given time to every destination, using transit with walking access,
+// egress and transfer legs or walking the whole way if it is faster.
+func (f *Feed) TravelTimes(origin provider.LatLng, departure time.Time, destinations []provider.LatLng) []time.Duration {
+	const unreached = math.MaxInt32
+
+	departure = departure.In(f.location)
+	y, m, d := departure.Date()
+	midnight := time.Date(y, m, d, 0, 0, 0, 0, f.location)
+	start := int32(departure.Sub(midnight) / time.Second)
+	limit := start + int32(MaxJourney/time.Second)
+
+	best := make([]int32, len(destinations))
+	egresses := make(map[int][]egress)
+	for j, dst := range destinations {
+		best[j] = start + walkingSeconds(haversine(origin.Lat, origin.Lng, dst.Lat, dst.Lng))
+		for _, n := range f.grid.nearby(dst.Lat, dst.Lng, AccessRadius) {
+			egresses[n.stop] = append(egresses[n.stop], egress{j, walkingSeconds(n.distance)})
+		}
+	}
+	bound := func() (b int32) {
+		for _, t := range best {
+			if t > b {
+				b = t
+			}
+		}
+		return b
+	}
+	arrivals := make([]int32, len(f.stops))
+	for i := range arrivals {
+		arrivals[i] = unreached
+	}
+	reach := func(s int, at int32) {
+		if at >= arrivals[s] {
+			return
+		}
+		arrivals[s] = at
+		for _, e := range egresses[s] {
+			if t := at + e.duration; t < best[e.destination] {
+				best[e.destination] = t
+			}
+		}
+	}
+	for _, n := range f.grid.nearby(origin.Lat, origin.Lng, AccessRadius) {
+		reach(n.stop, start+walkingSeconds(n.distance))
+	}
+
+	// connections of trips serviced on previous, current and next day are
+	// scanned together, shifted to seconds since midnight of departure day
+	var days [3]struct {
+		offset  int32
+		active  []bool
+		reached []bool
+		next    int
+	}
+	for k := range days {
+		date := midnight.AddDate(0, 0, k-1)
+		days[k].offset = int32(date.Sub(midnight) / time.Second)
+		days[k].active = make([]bool, len(f.services))
+		for s := range f.services {
+			days[k].active[s] = f.services[s].activeOn(yyyymmdd(date), date.Weekday())
+		}
+		days[k].reached = make([]bool, len(f.trips))
+		days[k].next = sort.Search(len(f.connections), func(i int) bool {
+			return f.connections[i].departure+days[k].offset >= start
+		})
+	}
+
+	for b := bound(); ; {
+		k := -1
+		var dep int32
+		for i := range days {
+			if days[i].next < len(f.connections) {
+				t := f.connections[days[i].next].departure + days[i].offset
+				if k < 0 || t < dep {
+					k, dep = i, t
+				}
+			}
+		}
+		if k < 0 || dep > b || dep > limit {
+			break
+		}
+		c := f.connections[days[k].next]
+		days[k].next++
+		if !days[k].active[f.trips[c.trip]] {
+			continue
+		}
+		if !days[k].reached[c.trip] && arrivals[c.from] > dep {
+			continue
+		}
+		days[k].reached[c.trip] = true
+		arr := c.arrival + days[k].offset
+		if arr < arrivals[c.to] {
+			reach(int(c.to), arr)
+			for _, fp := range f.footpaths[c.to] {
+				reach(fp.to, arr+fp.duration)
+			}
+			b = bound()
+		}
+	}
+
+	durations := make([]time.Duration, len(destinations))
+	for j, t := range best {
+		durations[j] = time.Duration(t-start) * time.Second
+	}
+	return durations
+}
+
+func yyyymmdd(t time.Time) int {
+	v, _ := strconv.Atoi(t.Format("20060102"))
+	return v
+}
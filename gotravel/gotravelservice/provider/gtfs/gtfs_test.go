@@ -0,0 +1,197 @@
+package gtfs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/provider"
+)
+
+// testFeed has stops on a meridian about 11 km apart, except B2 which is a
+// short transfer walk from B. On weekdays T1 rides A-B, T2 rides B2-C after
+// T3 that leaves B2 before a transfer from T1 is possible. T4 rides A-D on
+// Sundays only, and weekday service is removed on 2024-06-05.
+var testFeed = map[string]string{
+	"agency.txt": `agency_id,agency_name,agency_url,agency_timezone
+1,Test,http://example.com,UTC
+`,
+	"stops.txt": `stop_id,stop_name,stop_lat,stop_lon
+A,A,50.000,19.000
+B,B,50.100,19.000
+B2,B2,50.102,19.000
+C,C,50.200,19.000
+D,D,50.300,19.000
+`,
+	"calendar.txt": `service_id,monday,tuesday,wednesday,thursday,friday,saturday,sunday,start_date,end_date
+WK,1,1,1,1,1,0,0,20240101,20241231
+SUN,0,0,0,0,0,0,1,20240101,20241231
+`,
+	"calendar_dates.txt": `service_id,date,exception_type
+WK,20240605,2
+`,
+	"trips.txt": `route_id,service_id,trip_id
+R,WK,T1
+R,WK,T2
+R,WK,T3
+R,SUN,T4
+`,
+	"stop_times.txt": `trip_id,arrival_time,departure_time,stop_id,stop_sequence
+T1,08:00:00,08:00:00,A,1
+T1,08:20:00,08:20:00,B,2
+T2,08:30:00,08:30:00,B2,1
+T2,08:50:00,08:50:00,C,2
+T3,08:22:00,08:22:00,B2,1
+T3,08:40:00,08:40:00,C,2
+T4,08:00:00,08:00:00,A,1
+T4,,,B,2
+T4,09:00:00,09:00:00,D,3
+`,
+}
+
+func writeFeed(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func walking(from, to provider.LatLng) time.Duration {
+	return time.Duration(walkingSeconds(haversine(from.Lat, from.Lng, to.Lat, to.Lng))) * time.Second
+}
+
+func TestTravelTimes(t *testing.T) {
+	feed, err := Load(writeFeed(t, testFeed))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		a = provider.LatLng{Lat: 50.000, Lng: 19.000}
+		b = provider.LatLng{Lat: 50.100, Lng: 19.000}
+		c = provider.LatLng{Lat: 50.200, Lng: 19.000}
+		d = provider.LatLng{Lat: 50.300, Lng: 19.000}
+	)
+	tests := []struct {
+		name        string
+		departure   time.Time
+		destination provider.LatLng
+		want        time.Duration
+	}{
+		{
+			name:        "direct",
+			departure:   time.Date(2024, time.June, 4, 7, 55, 0, 0, time.UTC),
+			destination: b,
+			want:        25 * time.Minute,
+		},
+		{
+			name:        "transfer after missed connection",
+			departure:   time.Date(2024, time.June, 4, 7, 55, 0, 0, time.UTC),
+			destination: c,
+			want:        55 * time.Minute,
+		},
+		{
+			name:        "departed before arrival",
+			departure:   time.Date(2024, time.June, 4, 8, 1, 0, 0, time.UTC),
+			destination: b,
+			want:        walking(a, b),
+		},
+		{
+			name:        "stop not served on the day",
+			departure:   time.Date(2024, time.June, 4, 7, 55, 0, 0, time.UTC),
+			destination: d,
+			want:        walking(a, d),
+		},
+		{
+			name:        "sunday service past untimed stop",
+			departure:   time.Date(2024, time.June, 2, 7, 55, 0, 0, time.UTC),
+			destination: d,
+			want:        65 * time.Minute,
+		},
+		{
+			name:        "service removed on date",
+			departure:   time.Date(2024, time.June, 5, 7, 55, 0, 0, time.UTC),
+			destination: b,
+			want:        walking(a, b),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := feed.TravelTimes(a, tt.departure, []provider.LatLng{tt.destination})
+			if got[0] != tt.want {
+				t.Errorf("TravelTimes = %v, want %v", got[0], tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	without := func(names ...string) map[string]string {
+		files := make(map[string]string)
+		for k, v := range testFeed {
+			files[k] = v
+		}
+		for _, name := range names {
+			delete(files, name)
+		}
+		return files
+	}
+	with := func(name, content string) map[string]string {
+		files := without(name)
+		files[name] = content
+		return files
+	}
+
+	tests := []struct {
+		name  string
+		files map[string]string
+		file  string
+		line  int
+	}{
+		{
+			name:  "missing stops",
+			files: without("stops.txt"),
+			file:  "stops.txt",
+		},
+		{
+			name:  "missing calendars",
+			files: without("calendar.txt", "calendar_dates.txt"),
+			file:  "calendar.txt",
+		},
+		{
+			name: "unknown stop",
+			files: with("stop_times.txt", `trip_id,arrival_time,departure_time,stop_id,stop_sequence
+T1,08:00:00,08:00:00,A,1
+T1,08:20:00,08:20:00,X,2
+`),
+			file: "stop_times.txt",
+			line: 3,
+		},
+		{
+			name: "bad time",
+			files: with("stop_times.txt", `trip_id,arrival_time,departure_time,stop_id,stop_sequence
+T1,8:00,8:00,A,1
+`),
+			file: "stop_times.txt",
+			line: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeFeed(t, tt.files))
+			var bad ErrBadFeed
+			if !errors.As(err, &bad) {
+				t.Fatalf("Load error = %v, want ErrBadFeed", err)
+			}
+			if bad.File != tt.file || bad.Line != tt.line {
+				t.Errorf("Load error in %s at line %d, want %s at line %d", bad.File, bad.Line, tt.file, tt.line)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+// Package nominatim implements provider.PlaceResolver and
+// provider.DetailsFetcher querying OpenStreetMap Nominatim geocoding API
+// (https://nominatim.org/release-docs/latest/api/Overview/).
+package nominatim
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/provider"
+)
+
+// UserAgent identifies requests as required by Nominatim usage policy.
+const UserAgent = "go-travel (https://github.com/radekwlsk/go-travel)"
+
+type ErrBadPlaceID struct {
+	PlaceID string
+}
+
+func (err ErrBadPlaceID) Error() string {
+	return fmt.Sprintf("%q is not an OSM place ID, expected [NWR]<osm id> format", err.PlaceID)
+}
+
+type ErrResponse struct {
+	Status  string
+	Message string
+}
+
+func (err ErrResponse) Error() string {
+	return fmt.Sprintf("nominatim: %s - %s", err.Status, err.Message)
+}
+
+// Client resolves places with Nominatim search and fetches their details with
+// lookup endpoints of Nominatim server at given base URL. Place IDs are OSM
+// object IDs prefixed with N, W or R for node, way and relation.
+type Client struct {
+	baseURL string
+	client  *http.Client
+}
+
+func NewClient(baseURL string, client *http.Client) *Client {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  client,
+	}
+}
+
+// NewFactory returns provider.Factory replacing place resolver and details
+// fetcher of providers created by next factory with Client. Providers are
+// created even if next factory fails, e.g. for lack of API key, and only its
+// travel matrices are then unavailable.
+func NewFactory(next provider.Factory, baseURL string, client *http.Client) provider.Factory {
+	c := NewClient(baseURL, client)
+	return func(apiKey string) (provider.Provider, error) {
+		p, err := next(apiKey)
+		if err != nil {
+			p = provider.Unavailable{Err: err}
+		}
+		return provider.Composite{
+			PlaceResolver:  c,
+			DetailsFetcher: c,
+			MatrixFetcher:  p,
+		}, nil
+	}
+}
+
+type place struct {
+	OSMType     string            `json:"osm_type"`
+	OSMID       int64             `json:"osm_id"`
+	Lat         string            `json:"lat"`
+	Lon         string            `json:"lon"`
+	Name        string            `json:"name"`
+	DisplayName string            `json:"display_name"`
+	ExtraTags   map[string]string `json:"extratags"`
+}
+
+func (c *Client) PlaceID(ctx context.Context, query string) (string, error) {
+	q := url.Values{}
+	q.Set("q", query)
+	q.Set("limit", "1")
+	var places []place
+	if err := c.get(ctx, "search", q, &places); err != nil {
+		return "", err
+	}
+	if len(places) == 0 {
+		return "", provider.ErrZeroResults
+	}
+	return places[0].id()
+}
+
+func (c *Client) PlaceIDAt(ctx context.Context, ll provider.LatLng) (string, error) {
+	q := url.Values{}
+	q.Set("lat", strconv.FormatFloat(ll.Lat, 'f', -1, 64))
+	q.Set("lon", strconv.FormatFloat(ll.Lng, 'f', -1, 64))
+	var p place
+	if err := c.get(ctx, "reverse", q, &p); err != nil {
+		return "", err
+	}
+	return p.id()
+}
+
+func (p place) id() (string, error) {
+	if p.OSMType == "" {
+		return "", provider.ErrZeroResults
+	}
+	return strings.ToUpper(p.OSMType[:1]) + strconv.FormatInt(p.OSMID, 10), nil
+}
+
+func (c *Client) PlaceDetails(ctx context.Context, placeID string, language string) (provider.Details, error) {
+	if len(placeID) < 2 || !strings.Contains("NWR", placeID[:1]) {
+		return provider.Details{}, ErrBadPlaceID{placeID}
+	}
+	if _, err := strconv.ParseInt(placeID[1:], 10, 64); err != nil {
+		return provider.Details{}, ErrBadPlaceID{placeID}
+	}
+	q := url.Values{}
+	q.Set("osm_ids", placeID)
+	if language != "" {
+		q.Set("accept-language", language)
+	}
+	var places []place
+	if err := c.get(ctx, "lookup", q, &places); err != nil {
+		return provider.Details{}, err
+	}
+	if len(places) == 0 {
+		return provider.Details{}, provider.ErrZeroResults
+	}
+	p := places[0]
+
+	d := provider.Details{
+		Name:             p.Name,
+		FormattedAddress: p.DisplayName,
+		Tags:             p.ExtraTags,
+	}
+	if d.Name == "" {
+		d.Name = strings.SplitN(p.DisplayName, ",", 2)[0]
+	}
+	var err error
+	if d.LatLng.Lat, err = strconv.ParseFloat(p.Lat, 64); err != nil {
+		return provider.Details{}, err
+	}
+	if d.LatLng.Lng, err = strconv.ParseFloat(p.Lon, 64); err != nil {
+		return provider.Details{}, err
+	}
+	if oh, ok := p.ExtraTags["opening_hours"]; ok {
+		if d.Periods, err = ParseOpeningHours(oh); err != nil {
+			d.Periods = nil
+		}
+	}
+	if p.ExtraTags["disused"] == "yes" || p.ExtraTags["abandoned"] == "yes" {
+		d.PermanentlyClosed = true
+	}
+	return d, nil
+}
+
+func (c *Client) get(ctx context.Context, endpoint string, q url.Values, result interface{}) error {
+	q.Set("format", "jsonv2")
+	q.Set("extratags", "1")
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodGet,
+		fmt.Sprintf("%s/%s?%s", c.baseURL, endpoint, q.Encode()),
+		nil,
+	)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", UserAgent)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var body struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&body)
+		err = ErrResponse{Status: resp.Status, Message: body.Error.Message}
+		if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+			err = provider.ErrTemporary{Err: err}
+		}
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
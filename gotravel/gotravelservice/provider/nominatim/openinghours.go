@@ -0,0 +1,174 @@
+package nominatim
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/provider"
+)
+
+var ErrOpeningHoursUnsupported = errors.New("opening_hours value has unsupported rules")
+
+var weekdays = map[string]time.Weekday{
+	"Su": time.Sunday,
+	"Mo": time.Monday,
+	"Tu": time.Tuesday,
+	"We": time.Wednesday,
+	"Th": time.Thursday,
+	"Fr": time.Friday,
+	"Sa": time.Saturday,
+}
+
+var timeSpanRe = regexp.MustCompile(`^(\d{1,2}):(\d{2})-(\d{1,2}):(\d{2})$`)
+
+type span struct {
+	from, to int
+}
+
+// ParseOpeningHours parses the common subset of OSM opening_hours syntax
+// (https://wiki.openstreetmap.org/wiki/Key:opening_hours/specification):
+// "24/7" and rules of optional weekday ranges followed by time spans or
+// "off", like "Mo-Fr 09:00-12:00,13:00-17:00; Sa 10:00-02:00; Su off".
+// Later rules override earlier ones for the same days. Values with rules of
+// other selectors, e.g. months or public holidays, are rejected as a whole,
+// since hours of the remaining rules would be wrong on some days.
+func ParseOpeningHours(s string) ([]provider.Period, error) {
+	var week [7][]span
+	var parsed bool
+
+	for _, rule := range strings.FieldsFunc(strings.ReplaceAll(s, "||", ";"), func(r rune) bool { return r == ';' }) {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		if rule == "24/7" {
+			for d := range week {
+				week[d] = []span{{0, 24 * 60}}
+			}
+			parsed = true
+			continue
+		}
+
+		days := []time.Weekday{0, 1, 2, 3, 4, 5, 6}
+		fields := strings.Fields(rule)
+		if ds, err := parseWeekdays(fields[0]); err == nil {
+			days, fields = ds, fields[1:]
+		} else if !startsWithDigit(fields[0]) && fields[0] != "off" && fields[0] != "closed" {
+			return nil, ErrOpeningHoursUnsupported
+		}
+
+		var spans []span
+		switch times := strings.Join(fields, ""); times {
+		case "off", "closed":
+		case "":
+			spans = []span{{0, 24 * 60}}
+		default:
+			var err error
+			if spans, err = parseSpans(times); err != nil {
+				return nil, ErrOpeningHoursUnsupported
+			}
+		}
+		for _, d := range days {
+			week[d] = spans
+		}
+		parsed = true
+	}
+	if !parsed {
+		return nil, ErrOpeningHoursUnsupported
+	}
+
+	if allDay(week) {
+		// same as Google Maps representation of places that are always open
+		return []provider.Period{{Open: provider.PeriodTime{Day: time.Sunday, Time: "0000"}}}, nil
+	}
+	periods := make([]provider.Period, 0)
+	for d, spans := range week {
+		for _, sp := range spans {
+			periods = append(periods, provider.Period{
+				Open: provider.PeriodTime{
+					Day:  time.Weekday(d),
+					Time: hhmm(sp.from),
+				},
+				Close: provider.PeriodTime{
+					Day:  time.Weekday((d + sp.to/(24*60)) % 7),
+					Time: hhmm(sp.to % (24 * 60)),
+				},
+			})
+		}
+	}
+	return periods, nil
+}
+
+func allDay(week [7][]span) bool {
+	for _, spans := range week {
+		if len(spans) != 1 || spans[0].from != 0 || spans[0].to != 24*60 {
+			return false
+		}
+	}
+	return true
+}
+
+func startsWithDigit(s string) bool {
+	return s != "" && s[0] >= '0' && s[0] <= '9'
+}
+
+func parseWeekdays(s string) ([]time.Weekday, error) {
+	var days []time.Weekday
+	for _, part := range strings.Split(s, ",") {
+		bounds := strings.Split(part, "-")
+		from, ok := weekdays[bounds[0]]
+		if !ok {
+			return nil, fmt.Errorf("unknown weekday %q", bounds[0])
+		}
+		switch len(bounds) {
+		case 1:
+			days = append(days, from)
+		case 2:
+			to, ok := weekdays[bounds[1]]
+			if !ok {
+				return nil, fmt.Errorf("unknown weekday %q", bounds[1])
+			}
+			for d := from; ; d = (d + 1) % 7 {
+				days = append(days, d)
+				if d == to {
+					break
+				}
+			}
+		default:
+			return nil, fmt.Errorf("bad weekday range %q", part)
+		}
+	}
+	return days, nil
+}
+
+func parseSpans(s string) ([]span, error) {
+	var spans []span
+	for _, part := range strings.Split(s, ",") {
+		m := timeSpanRe.FindStringSubmatch(part)
+		if m == nil {
+			return nil, fmt.Errorf("bad time span %q", part)
+		}
+		var v [4]int
+		for i := range v {
+			v[i], _ = strconv.Atoi(m[i+1])
+		}
+		if v[0] > 24 || v[1] > 59 || v[2] > 24 || v[3] > 59 {
+			return nil, fmt.Errorf("bad time span %q", part)
+		}
+		sp := span{v[0]*60 + v[1], v[2]*60 + v[3]}
+		if sp.to <= sp.from {
+			// spans past midnight, e.g. 18:00-02:00
+			sp.to += 24 * 60
+		}
+		spans = append(spans, sp)
+	}
+	return spans, nil
+}
+
+func hhmm(minutes int) string {
+	return fmt.Sprintf("%02d%02d", minutes/60, minutes%60)
+}
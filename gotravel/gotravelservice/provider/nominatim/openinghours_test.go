@@ -0,0 +1,115 @@
+package nominatim
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/provider"
+)
+
+// period returns period opened on day at open and closed on closeDay at
+// close, times in 'hhmm' format.
+func period(day time.Weekday, open string, closeDay time.Weekday, close string) provider.Period {
+	return provider.Period{
+		Open:  provider.PeriodTime{Day: day, Time: open},
+		Close: provider.PeriodTime{Day: closeDay, Time: close},
+	}
+}
+
+func TestParseOpeningHours(t *testing.T) {
+	alwaysOpen := []provider.Period{{Open: provider.PeriodTime{Day: time.Sunday, Time: "0000"}}}
+	tests := []struct {
+		name  string
+		value string
+		want  []provider.Period
+		err   error
+	}{
+		{"always open", "24/7", alwaysOpen, nil},
+		{"every day all day", "Mo-Su 00:00-24:00", alwaysOpen, nil},
+		{
+			name:  "weekday range",
+			value: "Mo-Fr 09:00-17:00",
+			want: []provider.Period{
+				period(time.Monday, "0900", time.Monday, "1700"),
+				period(time.Tuesday, "0900", time.Tuesday, "1700"),
+				period(time.Wednesday, "0900", time.Wednesday, "1700"),
+				period(time.Thursday, "0900", time.Thursday, "1700"),
+				period(time.Friday, "0900", time.Friday, "1700"),
+			},
+		},
+		{
+			name:  "range wrapping around week",
+			value: "Sa-Mo 10:00-14:00",
+			want: []provider.Period{
+				period(time.Sunday, "1000", time.Sunday, "1400"),
+				period(time.Monday, "1000", time.Monday, "1400"),
+				period(time.Saturday, "1000", time.Saturday, "1400"),
+			},
+		},
+		{
+			name:  "list of weekdays",
+			value: "Tu,Th 8:30-12:00",
+			want: []provider.Period{
+				period(time.Tuesday, "0830", time.Tuesday, "1200"),
+				period(time.Thursday, "0830", time.Thursday, "1200"),
+			},
+		},
+		{
+			name:  "several spans a day",
+			value: "Mo 09:00-12:00,13:00-17:00",
+			want: []provider.Period{
+				period(time.Monday, "0900", time.Monday, "1200"),
+				period(time.Monday, "1300", time.Monday, "1700"),
+			},
+		},
+		{
+			name:  "off overrides earlier rule",
+			value: "Mo-We 09:00-17:00; Tu off",
+			want: []provider.Period{
+				period(time.Monday, "0900", time.Monday, "1700"),
+				period(time.Wednesday, "0900", time.Wednesday, "1700"),
+			},
+		},
+		{
+			name:  "later rule overrides earlier one",
+			value: "Mo-Tu 09:00-17:00; Tu 12:00-14:00",
+			want: []provider.Period{
+				period(time.Monday, "0900", time.Monday, "1700"),
+				period(time.Tuesday, "1200", time.Tuesday, "1400"),
+			},
+		},
+		{
+			name:  "span past midnight",
+			value: "Fr-Sa 22:00-02:00",
+			want: []provider.Period{
+				period(time.Friday, "2200", time.Saturday, "0200"),
+				period(time.Saturday, "2200", time.Sunday, "0200"),
+			},
+		},
+		{
+			name:  "span ending at midnight",
+			value: "Su 18:00-24:00",
+			want:  []provider.Period{period(time.Sunday, "1800", time.Monday, "0000")},
+		},
+		{"closed every day", "off", []provider.Period{}, nil},
+		{"public holidays", "PH off", nil, ErrOpeningHoursUnsupported},
+		{"public holidays after supported rule", "Mo-Fr 09:00-17:00; PH off", nil, ErrOpeningHoursUnsupported},
+		{"weekdays with public holidays", "Su,PH off", nil, ErrOpeningHoursUnsupported},
+		{"months", "Jan-Mar Mo 10:00-12:00", nil, ErrOpeningHoursUnsupported},
+		{"bad time", "Mo 9am-5pm", nil, ErrOpeningHoursUnsupported},
+		{"hour out of range", "Mo 25:00-26:00", nil, ErrOpeningHoursUnsupported},
+		{"empty", "", nil, ErrOpeningHoursUnsupported},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseOpeningHours(tt.value)
+			if err != tt.err {
+				t.Fatalf("ParseOpeningHours(%q) error = %v, want %v", tt.value, err, tt.err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseOpeningHours(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+// Package osrm implements provider.MatrixFetcher querying table service of
+// OSRM routing engine (http://project-osrm.org/docs/v5.24.0/api/#table-service).
+package osrm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/provider"
+)
+
+var (
+	ErrNoCoordinates = errors.New("OSRM requires coordinates of every waypoint")
+	ErrTableSize     = errors.New("OSRM table durations and distances must have row of every source " +
+		"with element of every destination")
+)
+
+// Profiles are OSRM profile names used in request URL for each travel mode.
+var Profiles = map[provider.TravelMode]string{
+	provider.TravelModeDriving:   "driving",
+	provider.TravelModeWalking:   "foot",
+	provider.TravelModeBicycling: "bike",
+}
+
+type ErrResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (err ErrResponse) Error() string {
+	return fmt.Sprintf("osrm: %s - %s", err.Code, err.Message)
+}
+
+// Servers maps travel modes to base URLs of OSRM servers handling them.
+type Servers map[provider.TravelMode]string
+
+// ParseServers parses comma separated list of mode=url pairs, e.g.
+// "driving=http://localhost:5000,walking=http://localhost:5001".
+func ParseServers(s string) (Servers, error) {
+	servers := make(Servers)
+	if s == "" {
+		return servers, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("OSRM server %q not in mode=url format", pair)
+		}
+		mode := provider.TravelMode(strings.TrimSpace(kv[0]))
+		if _, ok := Profiles[mode]; !ok {
+			return nil, fmt.Errorf("travel mode %q not supported by OSRM", mode)
+		}
+		if _, err := url.Parse(kv[1]); err != nil {
+			return nil, err
+		}
+		servers[mode] = strings.TrimRight(strings.TrimSpace(kv[1]), "/")
+	}
+	return servers, nil
+}
+
+// MatrixFetcher fetches matrices for travel modes that have OSRM server
+// configured and passes requests in other modes to the next fetcher.
+type MatrixFetcher struct {
+	servers Servers
+	client  *http.Client
+	next    provider.MatrixFetcher
+}
+
+func NewMatrixFetcher(servers Servers, client *http.Client, next provider.MatrixFetcher) *MatrixFetcher {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &MatrixFetcher{
+		servers: servers,
+		client:  client,
+		next:    next,
+	}
+}
+
+// NewFactory returns provider.Factory replacing matrix fetcher of providers
+// created by next factory with MatrixFetcher using given servers.
+func NewFactory(next provider.Factory, servers Servers, client *http.Client) provider.Factory {
+	return func(apiKey string) (provider.Provider, error) {
+		p, err := next(apiKey)
+		if err != nil {
+			return nil, err
+		}
+		return provider.Composite{
+			PlaceResolver:  p,
+			DetailsFetcher: p,
+			MatrixFetcher:  NewMatrixFetcher(servers, client, p),
+		}, nil
+	}
+}
+
+type tableResponse struct {
+	Code      string       `json:"code"`
+	Message   string       `json:"message"`
+	Durations [][]*float64 `json:"durations"`
+	Distances [][]*float64 `json:"distances"`
+}
+
+// fits checks whether both durations and distances of the table have
+// origins rows of destinations elements.
+func (t tableResponse) fits(origins, destinations int) bool {
+	for _, table := range [][][]*float64{t.Durations, t.Distances} {
+		if len(table) != origins {
+			return false
+		}
+		for _, row := range table {
+			if len(row) != destinations {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (f *MatrixFetcher) DistanceMatrix(ctx context.Context, r provider.MatrixRequest) (provider.Matrix, error) {
+	server, ok := f.servers[r.Mode]
+	if !ok {
+		return f.next.DistanceMatrix(ctx, r)
+	}
+
+	u, err := tableURL(server, Profiles[r.Mode], r)
+	if err != nil {
+		return provider.Matrix{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return provider.Matrix{}, err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return provider.Matrix{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+		return provider.Matrix{}, provider.ErrTemporary{Err: ErrResponse{Code: resp.Status}}
+	}
+	var table tableResponse
+	if err = json.NewDecoder(resp.Body).Decode(&table); err != nil {
+		return provider.Matrix{}, fmt.Errorf("osrm: %s: %v", resp.Status, err)
+	}
+	if table.Code != "Ok" {
+		return provider.Matrix{}, ErrResponse{Code: table.Code, Message: table.Message}
+	}
+	if !table.fits(len(r.Origins), len(r.Destinations)) {
+		return provider.Matrix{}, ErrTableSize
+	}
+
+	m := provider.Matrix{Rows: make([][]provider.MatrixElement, len(r.Origins))}
+	for i := range r.Origins {
+		m.Rows[i] = make([]provider.MatrixElement, len(r.Destinations))
+		for j := range r.Destinations {
+			dur, dist := table.Durations[i][j], table.Distances[i][j]
+			if dur == nil || dist == nil {
+				continue
+			}
+			m.Rows[i][j] = provider.MatrixElement{
+				OK:       true,
+				Duration: time.Duration(*dur * float64(time.Second)),
+				Distance: int64(*dist),
+			}
+		}
+	}
+	return m, nil
+}
+
+// MatrixLimits returns no limits for modes with OSRM server configured, OSRM
+// limits table size with --max-table-size option of the server instead.
+func (f *MatrixFetcher) MatrixLimits(mode provider.TravelMode) provider.MatrixLimits {
+	if _, ok := f.servers[mode]; ok {
+		return provider.MatrixLimits{}
+	}
+	return f.next.MatrixLimits(mode)
+}
+
+func tableURL(server, profile string, r provider.MatrixRequest) (string, error) {
+	var coordinates, sources, destinations []string
+	for i, w := range append(append([]provider.Waypoint{}, r.Origins...), r.Destinations...) {
+		if w.LatLng == nil {
+			return "", ErrNoCoordinates
+		}
+		coordinates = append(coordinates, fmt.Sprintf(
+			"%s,%s",
+			strconv.FormatFloat(w.LatLng.Lng, 'f', 6, 64),
+			strconv.FormatFloat(w.LatLng.Lat, 'f', 6, 64),
+		))
+		if i < len(r.Origins) {
+			sources = append(sources, strconv.Itoa(i))
+		} else {
+			destinations = append(destinations, strconv.Itoa(i))
+		}
+	}
+	return fmt.Sprintf(
+		"%s/table/v1/%s/%s?sources=%s&destinations=%s&annotations=duration,distance",
+		server,
+		profile,
+		strings.Join(coordinates, ";"),
+		strings.Join(sources, ";"),
+		strings.Join(destinations, ";"),
+	), nil
+}
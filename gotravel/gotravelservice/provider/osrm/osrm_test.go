@@ -0,0 +1,75 @@
+package osrm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/provider"
+)
+
+func TestDistanceMatrix(t *testing.T) {
+	a, b := provider.LatLng{Lat: 51.11, Lng: 17.03}, provider.LatLng{Lat: 51.10, Lng: 17.05}
+	request := provider.MatrixRequest{
+		Origins:      []provider.Waypoint{{LatLng: &a}, {LatLng: &b}},
+		Destinations: []provider.Waypoint{{LatLng: &a}, {LatLng: &b}},
+		Mode:         provider.TravelModeWalking,
+	}
+
+	tests := []struct {
+		name string
+		body string
+		err  error
+		want [][]provider.MatrixElement
+	}{
+		{
+			name: "full table",
+			body: `{"code":"Ok","durations":[[0,60],[90,null]],"distances":[[0,100],[120,null]]}`,
+			want: [][]provider.MatrixElement{
+				{{OK: true}, {OK: true, Duration: time.Minute, Distance: 100}},
+				{{OK: true, Duration: 90 * time.Second, Distance: 120}, {}},
+			},
+		},
+		{
+			name: "missing row",
+			body: `{"code":"Ok","durations":[[0,60]],"distances":[[0,100],[120,0]]}`,
+			err:  ErrTableSize,
+		},
+		{
+			name: "short row",
+			body: `{"code":"Ok","durations":[[0,60],[90,0]],"distances":[[0,100],[120]]}`,
+			err:  ErrTableSize,
+		},
+		{
+			name: "no distances",
+			body: `{"code":"Ok","durations":[[0,60],[90,0]]}`,
+			err:  ErrTableSize,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			f := NewMatrixFetcher(Servers{provider.TravelModeWalking: server.URL}, server.Client(), nil)
+			m, err := f.DistanceMatrix(context.Background(), request)
+			if err != tt.err {
+				t.Fatalf("DistanceMatrix error = %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			for i, row := range tt.want {
+				for j, want := range row {
+					if got := m.Rows[i][j]; got != want {
+						t.Errorf("element %d,%d = %+v, want %+v", i, j, got, want)
+					}
+				}
+			}
+		})
+	}
+}
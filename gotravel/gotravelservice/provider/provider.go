@@ -0,0 +1,214 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrZeroResults = errors.New("provider query returned no result")
+
+	ErrAPIKeyEmpty = errors.New("Google Maps API Key must be configured for the tenant or provided " +
+		"as 'apiKey' of the request")
+)
+
+// ErrTemporary wraps errors of calls that may succeed if retried later, like
+// exceeded query rate or internal server errors.
+type ErrTemporary struct {
+	Err error
+}
+
+func (err ErrTemporary) Error() string {
+	return err.Err.Error()
+}
+
+func (err ErrTemporary) Unwrap() error {
+	return err.Err
+}
+
+// IsTemporary reports whether err or any error it wraps is ErrTemporary.
+func IsTemporary(err error) bool {
+	var t ErrTemporary
+	return errors.As(err, &t)
+}
+
+// Provider is a source of geographical data used by the service to resolve
+// described places, fetch their details and travel matrices between them.
+type Provider interface {
+	PlaceResolver
+	DetailsFetcher
+	MatrixFetcher
+}
+
+// PlaceResolver finds ID of the place best matching a text query or of the
+// place at given coordinates.
+type PlaceResolver interface {
+	PlaceID(ctx context.Context, query string) (string, error)
+	PlaceIDAt(ctx context.Context, ll LatLng) (string, error)
+}
+
+// DetailsFetcher fetches details of the place identified by ID returned from
+// PlaceResolver of the same provider.
+type DetailsFetcher interface {
+	PlaceDetails(ctx context.Context, placeID string, language string) (Details, error)
+}
+
+// MatrixFetcher fetches travel durations and distances between all origins
+// and all destinations of the request. Requests must not exceed limits the
+// fetcher reports for their travel mode.
+type MatrixFetcher interface {
+	DistanceMatrix(ctx context.Context, r MatrixRequest) (Matrix, error)
+	MatrixLimits(mode TravelMode) MatrixLimits
+}
+
+// Factory creates Provider for the API key supplied with the request.
+type Factory func(apiKey string) (Provider, error)
+
+// Unavailable is Provider failing every call with Err, used in place of
+// provider that could not be created when it may not be needed at all.
+type Unavailable struct {
+	Err error
+}
+
+func (u Unavailable) PlaceID(context.Context, string) (string, error) {
+	return "", u.Err
+}
+
+func (u Unavailable) PlaceIDAt(context.Context, LatLng) (string, error) {
+	return "", u.Err
+}
+
+func (u Unavailable) PlaceDetails(context.Context, string, string) (Details, error) {
+	return Details{}, u.Err
+}
+
+func (u Unavailable) DistanceMatrix(context.Context, MatrixRequest) (Matrix, error) {
+	return Matrix{}, u.Err
+}
+
+func (u Unavailable) MatrixLimits(TravelMode) MatrixLimits {
+	return MatrixLimits{}
+}
+
+// Composite is Provider combining separate implementations of its parts,
+// e.g. to fetch travel matrices from a different source than place details.
+type Composite struct {
+	PlaceResolver
+	DetailsFetcher
+	MatrixFetcher
+}
+
+// Backends name sources answering provider calls, like "google" or URL of
+// Nominatim server, so that responses of different sources can be told apart.
+type Backends struct {
+	// Places answers place resolving and details calls.
+	Places string
+	// Matrix answers travel matrix calls in modes not in Modes.
+	Matrix string
+	Modes  map[TravelMode]string
+}
+
+// Serve makes name the source of travel matrices in mode.
+func (b *Backends) Serve(mode TravelMode, name string) {
+	if b.Modes == nil {
+		b.Modes = make(map[TravelMode]string)
+	}
+	b.Modes[mode] = name
+}
+
+// MatrixIn returns source of travel matrices in mode.
+func (b Backends) MatrixIn(mode TravelMode) string {
+	if name, ok := b.Modes[mode]; ok {
+		return name
+	}
+	return b.Matrix
+}
+
+type TravelMode string
+
+const (
+	TravelModeWalking   TravelMode = "walking"
+	TravelModeBicycling TravelMode = "bicycling"
+	TravelModeTransit   TravelMode = "transit"
+	TravelModeDriving   TravelMode = "driving"
+)
+
+type LatLng struct {
+	Lat float64 `json:"lat"`
+	Lng float64 `json:"lng"`
+}
+
+func (ll LatLng) String() string {
+	return fmt.Sprintf("%f,%f", ll.Lat, ll.Lng)
+}
+
+type Details struct {
+	Name              string
+	FormattedAddress  string
+	PermanentlyClosed bool
+	LatLng            LatLng
+	// UTCOffset is offset of place's time zone in minutes, nil if unknown.
+	UTCOffset *int
+	// Periods are weekly opening periods, nil if opening hours are unknown.
+	Periods []Period
+	// Tags are additional provider specific attributes of the place.
+	Tags map[string]string
+}
+
+type Period struct {
+	Open  PeriodTime
+	Close PeriodTime
+}
+
+// PeriodTime is a day of week and time of day in 24-hour hhmm format.
+type PeriodTime struct {
+	Day  time.Weekday
+	Time string
+}
+
+// Waypoint is an origin or destination of matrix request, identified by
+// address, coordinates or both.
+type Waypoint struct {
+	Address string
+	LatLng  *LatLng
+}
+
+func (w Waypoint) String() string {
+	if w.Address != "" {
+		return w.Address
+	}
+	if w.LatLng != nil {
+		return w.LatLng.String()
+	}
+	return ""
+}
+
+// MatrixLimits are maximal numbers of origins, destinations and elements,
+// i.e. origins times destinations, of single matrix request, zero if there is
+// no limit.
+type MatrixLimits struct {
+	MaxOrigins      int `json:"maxOrigins"`
+	MaxDestinations int `json:"maxDestinations"`
+	MaxElements     int `json:"maxElements"`
+}
+
+type MatrixRequest struct {
+	Origins       []Waypoint
+	Destinations  []Waypoint
+	DepartureTime time.Time
+	Mode          TravelMode
+}
+
+// Matrix holds one row of elements for every origin of the request, each
+// with one element for every destination.
+type Matrix struct {
+	Rows [][]MatrixElement
+}
+
+type MatrixElement struct {
+	OK       bool
+	Duration time.Duration
+	Distance int64
+}
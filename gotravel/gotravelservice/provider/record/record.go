@@ -0,0 +1,235 @@
+// Package record implements providers that record responses of another
+// provider to a fixture directory and replay them back without network access.
+package record
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/provider"
+)
+
+type ErrFixtureNotFound struct {
+	Kind    string
+	Request interface{}
+}
+
+func (err ErrFixtureNotFound) Error() string {
+	return fmt.Sprintf("no recorded %s response for request %+v", err.Kind, err.Request)
+}
+
+const (
+	kindPlaceID        = "autocomplete"
+	kindPlaceIDAt      = "reverse"
+	kindPlaceDetails   = "details"
+	kindDistanceMatrix = "matrix"
+	kindMatrixLimits   = "limits"
+)
+
+type placeIDRequest struct {
+	Query string `json:"query"`
+}
+
+type placeDetailsRequest struct {
+	PlaceID  string `json:"placeId"`
+	Language string `json:"language"`
+}
+
+type matrixLimitsRequest struct {
+	Mode provider.TravelMode `json:"mode"`
+}
+
+type fixture struct {
+	Kind        string          `json:"kind"`
+	Request     interface{}     `json:"request"`
+	Response    json.RawMessage `json:"response,omitempty"`
+	ZeroResults bool            `json:"zeroResults,omitempty"`
+}
+
+type fixtures struct {
+	dir string
+}
+
+func (f fixtures) path(kind string, request interface{}) (string, error) {
+	key, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(append([]byte(kind), key...))
+	return filepath.Join(f.dir, fmt.Sprintf("%s-%s.json", kind, hex.EncodeToString(sum[:16]))), nil
+}
+
+func (f fixtures) save(kind string, request interface{}, response interface{}, respErr error) error {
+	path, err := f.path(kind, request)
+	if err != nil {
+		return err
+	}
+	fx := fixture{Kind: kind, Request: request}
+	if respErr == provider.ErrZeroResults {
+		fx.ZeroResults = true
+	} else if fx.Response, err = json.Marshal(response); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(fx, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(f.dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(f.dir, kind)
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (f fixtures) load(kind string, request interface{}, response interface{}) error {
+	path, err := f.path(kind, request)
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrFixtureNotFound{Kind: kind, Request: request}
+	} else if err != nil {
+		return err
+	}
+	var fx fixture
+	if err = json.Unmarshal(data, &fx); err != nil {
+		return err
+	}
+	if fx.ZeroResults {
+		return provider.ErrZeroResults
+	}
+	return json.Unmarshal(fx.Response, response)
+}
+
+// Recorder is provider.Provider passing all calls to the next provider and
+// saving every successful response to fixture directory.
+type Recorder struct {
+	next     provider.Provider
+	fixtures fixtures
+}
+
+func NewRecorder(next provider.Provider, dir string) *Recorder {
+	return &Recorder{next: next, fixtures: fixtures{dir}}
+}
+
+// NewRecordingFactory returns provider.Factory wrapping providers created by
+// next factory with Recorder saving fixtures to dir.
+func NewRecordingFactory(next provider.Factory, dir string) provider.Factory {
+	return func(apiKey string) (provider.Provider, error) {
+		p, err := next(apiKey)
+		if err != nil {
+			return nil, err
+		}
+		return NewRecorder(p, dir), nil
+	}
+}
+
+func (r *Recorder) PlaceID(ctx context.Context, query string) (string, error) {
+	id, err := r.next.PlaceID(ctx, query)
+	if err != nil && err != provider.ErrZeroResults {
+		return id, err
+	}
+	if saveErr := r.fixtures.save(kindPlaceID, placeIDRequest{query}, id, err); saveErr != nil {
+		return id, saveErr
+	}
+	return id, err
+}
+
+func (r *Recorder) PlaceIDAt(ctx context.Context, ll provider.LatLng) (string, error) {
+	id, err := r.next.PlaceIDAt(ctx, ll)
+	if err != nil && err != provider.ErrZeroResults {
+		return id, err
+	}
+	if saveErr := r.fixtures.save(kindPlaceIDAt, ll, id, err); saveErr != nil {
+		return id, saveErr
+	}
+	return id, err
+}
+
+func (r *Recorder) PlaceDetails(ctx context.Context, placeID string, language string) (provider.Details, error) {
+	d, err := r.next.PlaceDetails(ctx, placeID, language)
+	if err != nil {
+		return d, err
+	}
+	return d, r.fixtures.save(kindPlaceDetails, placeDetailsRequest{placeID, language}, d, nil)
+}
+
+func (r *Recorder) DistanceMatrix(ctx context.Context, req provider.MatrixRequest) (provider.Matrix, error) {
+	m, err := r.next.DistanceMatrix(ctx, req)
+	if err != nil {
+		return m, err
+	}
+	return m, r.fixtures.save(kindDistanceMatrix, req, m, nil)
+}
+
+// MatrixLimits returns limits of the next provider and saves them, so that
+// Replayer splits matrix requests the same way.
+func (r *Recorder) MatrixLimits(mode provider.TravelMode) provider.MatrixLimits {
+	l := r.next.MatrixLimits(mode)
+	r.fixtures.save(kindMatrixLimits, matrixLimitsRequest{mode}, l, nil)
+	return l
+}
+
+// Replayer is provider.Provider serving responses saved by Recorder, it
+// returns ErrFixtureNotFound for requests that were not recorded.
+type Replayer struct {
+	fixtures fixtures
+}
+
+func NewReplayer(dir string) *Replayer {
+	return &Replayer{fixtures: fixtures{dir}}
+}
+
+// NewReplayFactory returns provider.Factory creating Replayer reading
+// fixtures from dir regardless of API key.
+func NewReplayFactory(dir string) provider.Factory {
+	return func(string) (provider.Provider, error) {
+		return NewReplayer(dir), nil
+	}
+}
+
+func (r *Replayer) PlaceID(_ context.Context, query string) (id string, err error) {
+	err = r.fixtures.load(kindPlaceID, placeIDRequest{query}, &id)
+	return id, err
+}
+
+func (r *Replayer) PlaceIDAt(_ context.Context, ll provider.LatLng) (id string, err error) {
+	err = r.fixtures.load(kindPlaceIDAt, ll, &id)
+	return id, err
+}
+
+func (r *Replayer) PlaceDetails(_ context.Context, placeID string, language string) (d provider.Details, err error) {
+	err = r.fixtures.load(kindPlaceDetails, placeDetailsRequest{placeID, language}, &d)
+	return d, err
+}
+
+func (r *Replayer) DistanceMatrix(_ context.Context, req provider.MatrixRequest) (m provider.Matrix, err error) {
+	err = r.fixtures.load(kindDistanceMatrix, req, &m)
+	return m, err
+}
+
+// MatrixLimits returns recorded limits or no limits if none were recorded.
+func (r *Replayer) MatrixLimits(mode provider.TravelMode) (l provider.MatrixLimits) {
+	r.fixtures.load(kindMatrixLimits, matrixLimitsRequest{mode}, &l)
+	return l
+}
@@ -0,0 +1,172 @@
+package retry
+
+import (
+	"context"
+	"crypto/sha256"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/provider"
+)
+
+// Limited calls next provider when rate limiter of its API key allows.
+type Limited struct {
+	next     provider.Provider
+	limiters *limiters
+	key      [sha256.Size]byte
+}
+
+// NewLimitedFactory returns provider.Factory wrapping providers created by
+// next factory with Limited, providers created for the same API key share
+// single rate limiter. It should wrap only providers with quota, e.g. before
+// travel matrices are replaced with ones computed locally.
+func NewLimitedFactory(next provider.Factory, options Options) provider.Factory {
+	ls := newLimiters(options)
+	return func(apiKey string) (provider.Provider, error) {
+		p, err := next(apiKey)
+		if err != nil {
+			return nil, err
+		}
+		return &Limited{next: p, limiters: ls, key: sha256.Sum256([]byte(apiKey))}, nil
+	}
+}
+
+func (l *Limited) PlaceID(ctx context.Context, query string) (string, error) {
+	if err := l.limiters.wait(ctx, l.key); err != nil {
+		return "", err
+	}
+	return l.next.PlaceID(ctx, query)
+}
+
+func (l *Limited) PlaceIDAt(ctx context.Context, ll provider.LatLng) (string, error) {
+	if err := l.limiters.wait(ctx, l.key); err != nil {
+		return "", err
+	}
+	return l.next.PlaceIDAt(ctx, ll)
+}
+
+func (l *Limited) PlaceDetails(ctx context.Context, placeID string, language string) (provider.Details, error) {
+	if err := l.limiters.wait(ctx, l.key); err != nil {
+		return provider.Details{}, err
+	}
+	return l.next.PlaceDetails(ctx, placeID, language)
+}
+
+func (l *Limited) DistanceMatrix(ctx context.Context, r provider.MatrixRequest) (provider.Matrix, error) {
+	if err := l.limiters.wait(ctx, l.key); err != nil {
+		return provider.Matrix{}, err
+	}
+	return l.next.DistanceMatrix(ctx, r)
+}
+
+func (l *Limited) MatrixLimits(mode provider.TravelMode) provider.MatrixLimits {
+	return l.next.MatrixLimits(mode)
+}
+
+// Transport makes HTTP requests with next round tripper when its rate
+// limiter allows. Unlike Limited it limits all requests regardless of API
+// key, for servers with usage policy of their own, like public Nominatim
+// instance allowing 1 request per second.
+type Transport struct {
+	next    http.RoundTripper
+	limiter *rate.Limiter
+}
+
+// NewTransport returns Transport allowing Rate requests per second of
+// options, Burst of them at once. Zero Rate disables the limit, nil next is
+// replaced with http.DefaultTransport.
+func NewTransport(next http.RoundTripper, options Options) *Transport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	ls := newLimiters(options)
+	return &Transport{next: next, limiter: rate.NewLimiter(ls.limit, ls.burst)}
+}
+
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if err := t.limiter.Wait(r.Context()); err != nil {
+		if r.Body != nil {
+			r.Body.Close()
+		}
+		return nil, err
+	}
+	return t.next.RoundTrip(r)
+}
+
+// limiters holds rate limiters of API keys by their hashes, so that keys are
+// not kept in memory. Limiters unused for a minute, or for longer than it
+// takes them to refill, are dropped, new ones created in their place behave
+// the same.
+type limiters struct {
+	mtx     sync.Mutex
+	limit   rate.Limit
+	burst   int
+	idle    time.Duration
+	swept   time.Time
+	entries map[[sha256.Size]byte]*limiterEntry
+}
+
+type limiterEntry struct {
+	limiter *rate.Limiter
+	used    time.Time
+}
+
+func newLimiters(options Options) *limiters {
+	ls := &limiters{
+		limit:   rate.Inf,
+		burst:   options.Burst,
+		idle:    time.Minute,
+		entries: make(map[[sha256.Size]byte]*limiterEntry),
+	}
+	if ls.burst < 1 {
+		ls.burst = 1
+	}
+	if options.Rate > 0 {
+		ls.limit = rate.Limit(options.Rate)
+		if refill := time.Duration(float64(ls.burst) / options.Rate * float64(time.Second)); refill > ls.idle {
+			ls.idle = refill
+		}
+	}
+	return ls
+}
+
+// wait blocks until limiter of the key allows a call.
+func (ls *limiters) wait(ctx context.Context, key [sha256.Size]byte) error {
+	err := ls.get(key).Wait(ctx)
+	ls.touch(key)
+	return err
+}
+
+func (ls *limiters) get(key [sha256.Size]byte) *rate.Limiter {
+	ls.mtx.Lock()
+	defer ls.mtx.Unlock()
+	now := time.Now()
+	if now.Sub(ls.swept) > ls.idle {
+		for k, e := range ls.entries {
+			if now.Sub(e.used) > ls.idle {
+				delete(ls.entries, k)
+			}
+		}
+		ls.swept = now
+	}
+	e, ok := ls.entries[key]
+	if !ok {
+		e = &limiterEntry{limiter: rate.NewLimiter(ls.limit, ls.burst)}
+		ls.entries[key] = e
+	}
+	e.used = now
+	return e.limiter
+}
+
+// touch marks limiter of the key used after waiting for it, so that it is not
+// dropped while tokens are reserved.
+func (ls *limiters) touch(key [sha256.Size]byte) {
+	ls.mtx.Lock()
+	defer ls.mtx.Unlock()
+	if e, ok := ls.entries[key]; ok {
+		e.used = time.Now()
+	}
+}
@@ -0,0 +1,81 @@
+package retry
+
+import (
+	"context"
+	"crypto/sha256"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLimitersShareAndExpire(t *testing.T) {
+	ls := newLimiters(Options{Rate: 1, Burst: 1})
+	a, b := sha256.Sum256([]byte("key-a")), sha256.Sum256([]byte("key-b"))
+
+	if ls.get(a) != ls.get(a) {
+		t.Error("limiters of the same key differ")
+	}
+	if ls.get(a) == ls.get(b) {
+		t.Error("limiters of different keys are shared")
+	}
+
+	idle := time.Now().Add(-2 * ls.idle)
+	ls.entries[a].used = idle
+	ls.swept = idle
+	ls.get(b)
+	if _, ok := ls.entries[a]; ok {
+		t.Error("idle limiter not dropped")
+	}
+	if _, ok := ls.entries[b]; !ok {
+		t.Error("used limiter dropped")
+	}
+}
+
+func TestLimitersIdleCoversRefill(t *testing.T) {
+	tests := []struct {
+		options Options
+		idle    time.Duration
+	}{
+		{Options{Rate: 10, Burst: 10}, time.Minute},
+		{Options{Rate: 0.1, Burst: 10}, 100 * time.Second},
+		{Options{Rate: 0, Burst: 0}, time.Minute},
+	}
+	for _, tt := range tests {
+		if idle := newLimiters(tt.options).idle; idle != tt.idle {
+			t.Errorf("idle of %+v = %v, want %v", tt.options, idle, tt.idle)
+		}
+	}
+}
+
+func TestTransport(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+	client := &http.Client{Transport: NewTransport(nil, Options{Rate: 20, Burst: 1})}
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	// the first request is made at once, the next ones every 50ms
+	if elapsed := time.Since(start); elapsed < 190*time.Millisecond {
+		t.Errorf("5 requests made in %v, want at most 20 per second", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	if _, err := client.Do(req); err == nil {
+		t.Error("request of cancelled context made")
+	}
+	if requests != 5 {
+		t.Errorf("%d requests made, want 5", requests)
+	}
+}
@@ -0,0 +1,143 @@
+// Package retry implements provider wrapping another one with client side
+// rate limiting and retries of temporary errors with exponential backoff.
+package retry
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/provider"
+)
+
+// Options configure retries and rate limiting of provider calls.
+type Options struct {
+	// MaxAttempts is the maximal number of calls made for single request,
+	// including the first one.
+	MaxAttempts int
+	// BaseDelay is the upper bound of delay before the first retry, it is
+	// doubled for every next retry up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Rate is number of calls per second allowed for single API key, Burst
+	// calls can be made at once. Zero Rate disables rate limiting.
+	Rate  float64
+	Burst int
+}
+
+// DefaultOptions retry up to 3 times and allow 10 calls per second.
+var DefaultOptions = Options{
+	MaxAttempts: 4,
+	BaseDelay:   200 * time.Millisecond,
+	MaxDelay:    5 * time.Second,
+	Rate:        10,
+	Burst:       10,
+}
+
+// Provider retries calls of next provider that failed with
+// provider.ErrTemporary after randomized exponential backoff as long as
+// request's context deadline allows it.
+type Provider struct {
+	next    provider.Provider
+	options Options
+	logger  log.Logger
+}
+
+func New(next provider.Provider, options Options, logger log.Logger) *Provider {
+	return &Provider{
+		next:    next,
+		options: options,
+		logger:  logger,
+	}
+}
+
+// NewFactory returns provider.Factory wrapping providers created by next
+// factory with Provider.
+func NewFactory(next provider.Factory, options Options, logger log.Logger) provider.Factory {
+	return func(apiKey string) (provider.Provider, error) {
+		p, err := next(apiKey)
+		if err != nil {
+			return nil, err
+		}
+		return New(p, options, logger), nil
+	}
+}
+
+func (p *Provider) PlaceID(ctx context.Context, query string) (placeID string, err error) {
+	err = p.do(ctx, "autocomplete", func() error {
+		placeID, err = p.next.PlaceID(ctx, query)
+		return err
+	})
+	return placeID, err
+}
+
+func (p *Provider) PlaceIDAt(ctx context.Context, ll provider.LatLng) (placeID string, err error) {
+	err = p.do(ctx, "reverse", func() error {
+		placeID, err = p.next.PlaceIDAt(ctx, ll)
+		return err
+	})
+	return placeID, err
+}
+
+func (p *Provider) PlaceDetails(ctx context.Context, placeID string, language string) (d provider.Details, err error) {
+	err = p.do(ctx, "details", func() error {
+		d, err = p.next.PlaceDetails(ctx, placeID, language)
+		return err
+	})
+	return d, err
+}
+
+func (p *Provider) DistanceMatrix(ctx context.Context, r provider.MatrixRequest) (m provider.Matrix, err error) {
+	err = p.do(ctx, "matrix", func() error {
+		m, err = p.next.DistanceMatrix(ctx, r)
+		return err
+	})
+	return m, err
+}
+
+func (p *Provider) MatrixLimits(mode provider.TravelMode) provider.MatrixLimits {
+	return p.next.MatrixLimits(mode)
+}
+
+// do calls fn until it succeeds, fails with error that is not temporary,
+// attempts run out or the next attempt would not finish before deadline.
+func (p *Provider) do(ctx context.Context, call string, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || !provider.IsTemporary(err) || attempt >= p.options.MaxAttempts {
+			return err
+		}
+
+		delay := p.backoff(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+			return err
+		}
+		p.logger.Log("msg", "retrying provider call", "call", call, "attempt", attempt, "delay", delay, "err", err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns random delay up to BaseDelay doubled for every attempt made
+// and capped at MaxDelay, so that concurrent retries are spread in time.
+func (p *Provider) backoff(attempt int) time.Duration {
+	limit := p.options.BaseDelay
+	for i := 1; i < attempt && limit < p.options.MaxDelay; i++ {
+		limit *= 2
+	}
+	if p.options.MaxDelay > 0 && limit > p.options.MaxDelay {
+		limit = p.options.MaxDelay
+	}
+	if limit <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(limit)))
+}
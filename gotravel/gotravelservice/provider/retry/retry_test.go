@@ -0,0 +1,101 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/provider"
+)
+
+func TestProviderDo(t *testing.T) {
+	temporary := provider.ErrTemporary{Err: errors.New("over query limit")}
+	permanent := errors.New("request denied")
+	quick := Options{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond}
+
+	tests := []struct {
+		name    string
+		options Options
+		errs    []error
+		timeout time.Duration
+		calls   int
+		err     error
+	}{
+		{
+			name:    "success",
+			options: quick,
+			calls:   1,
+		},
+		{
+			name:    "temporary errors retried",
+			options: quick,
+			errs:    []error{temporary, temporary},
+			calls:   3,
+		},
+		{
+			name:    "attempts run out",
+			options: quick,
+			errs:    []error{temporary, temporary, temporary, temporary},
+			calls:   3,
+			err:     temporary,
+		},
+		{
+			name:    "permanent error",
+			options: quick,
+			errs:    []error{permanent, temporary},
+			calls:   1,
+			err:     permanent,
+		},
+		{
+			name: "no time left for backoff",
+			// random backoff up to a year is practically never shorter
+			options: Options{MaxAttempts: 3, BaseDelay: 365 * 24 * time.Hour},
+			errs:    []error{temporary, temporary},
+			timeout: 50 * time.Millisecond,
+			calls:   1,
+			err:     temporary,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+			var calls int
+			start := time.Now()
+			err := New(nil, tt.options, log.NewNopLogger()).do(ctx, "test", func() error {
+				calls++
+				if calls <= len(tt.errs) {
+					return tt.errs[calls-1]
+				}
+				return nil
+			})
+			if err != tt.err || calls != tt.calls {
+				t.Errorf("do returned %v after %d calls, want %v after %d", err, calls, tt.err, tt.calls)
+			}
+			if tt.timeout > 0 && time.Since(start) >= tt.timeout {
+				t.Errorf("do returned after %v, want before the deadline", time.Since(start))
+			}
+		})
+	}
+}
+
+func TestProviderDoCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := New(nil, Options{MaxAttempts: 3, BaseDelay: time.Hour, MaxDelay: time.Hour}, log.NewNopLogger())
+	var calls int
+	err := p.do(ctx, "test", func() error {
+		calls++
+		cancel()
+		return provider.ErrTemporary{Err: errors.New("server error")}
+	})
+	if err != context.Canceled || calls != 1 {
+		t.Errorf("do returned %v after %d calls, want context.Canceled after 1", err, calls)
+	}
+}
@@ -4,39 +4,48 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"reflect"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/go-kit/kit/log"
-	"github.com/gregjones/httpcache"
 	"github.com/mitchellh/mapstructure"
 	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/planner"
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/provider"
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/tenant"
 	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/trip"
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/usage"
 	"github.com/radekwlsk/go-travel/utils"
-	"googlemaps.github.io/maps"
 )
 
 // Service interface definition and basic service methods implementation,
 // the actual actions performed by service on data.
 type Service interface {
 	TripPlan(context.Context, trip.Configuration) (trip.Trip, error)
+	Estimate(context.Context, trip.Configuration) (Estimate, error)
+	Usage(context.Context) (usage.Report, error)
 }
 
-func New(logger log.Logger) Service {
+func New(logger log.Logger, config Config) Service {
 	var s Service
 	{
-		s = NewService()
-		s = NewLoggingMiddleware(log.With(logger, "layer", "service"))(s)
+		s = NewService(config)
+		if config.Tenants != nil {
+			s = NewAuthMiddleware(config.Tenants, config.AllowClientKeys)(s)
+		}
+		// logging is outermost, so that rejected requests are logged too
+		s = NewLoggingMiddleware(log.With(logger, "layer", "service"), config.Tenants)(s)
 	}
 	return s
 }
 
 var (
-	ErrAPIKeyEmpty = errors.New("request must contain Google Maps API Key as 'apiKey'")
+	ErrAPIKeyEmpty = provider.ErrAPIKeyEmpty
 
-	ErrModeEmpty = errors.New("request places description mode must be provided as 'mode'")
+	ErrModeEmpty = errors.New("request places description mode must be provided as 'mode' of the request " +
+		"or of every place")
 
 	ErrTripStartEmpty = errors.New("request must contain trip start time in 'YYYY-MM-DDThh:mm:ssZ' format as" +
 		" 'tripStart'")
@@ -56,6 +65,34 @@ var (
 
 	ErrTwoEndPlaces = errors.New("more than one place marked as end")
 
+	ErrTwoLodgings = errors.New("more than one place marked as lodging")
+
+	ErrLodgingStartEnd = errors.New("trip with lodging starts and ends there, no place can be marked as start or end")
+
+	ErrBadDailyWindow = errors.New("dailyStart and dailyEnd must be provided together in 'hh:mm' format, " +
+		"with dailyStart before dailyEnd")
+
+	ErrNoDailyWindow = errors.New("daily window does not overlap with trip time")
+
+	ErrNoLodging = errors.New("trip spanning several days must have a place marked as lodging")
+
+	ErrBadAppointment = errors.New("appointment time must be in RFC3339 format within trip time, " +
+		"with non-negative early and late tolerance in minutes")
+
+	ErrBadPrecedence = errors.New("precedence must refer to two different places by their index in 'places'")
+
+	ErrPrecedenceCycle = errors.New("precedence constraints form a cycle")
+
+	ErrPrecedenceImpossible = errors.New("precedence can't be satisfied, start place, end place and lodging can't " +
+		"follow, end place can't precede other places and appointments must be in the required order")
+
+	ErrBadBreak = trip.ErrBadBreak
+
+	ErrBadException = trip.ErrBadException
+
+	ErrBadVisitWindow = errors.New("earliest and latest visit times of place must be in 'hh:mm' format, " +
+		"with earliest before latest")
+
 	ErrBadMode = errors.New(fmt.Sprintf("place description mode is not valid, available modes are: %s",
 		strings.Join(trip.ModeOptions, ", ")))
 
@@ -72,6 +109,10 @@ func (err ErrBadDescription) Error() string {
 	return fmt.Sprintf("could not parse place description of %s", err.Place.Description)
 }
 
+// ErrRequiredMissed is returned if no plan visits all required places and
+// places with appointments.
+type ErrRequiredMissed = planner.ErrRequiredMissed
+
 type ErrDescriptionInaccurate struct {
 	Place *trip.PlaceConfig
 }
@@ -80,136 +121,121 @@ func (err ErrDescriptionInaccurate) Error() string {
 	return fmt.Sprintf("description not accurate, no results found for %s", err.Place.Description)
 }
 
+// Config is server side configuration of the service.
+type Config struct {
+	// Providers creates provider used to plan every trip.
+	Providers provider.Factory
+	// Backends name sources answering calls of Providers, estimates count
+	// only calls answered by Google Maps.
+	Backends provider.Backends
+	// AllowPastTrips disables the check that trip times are not in the past,
+	// used to replay recorded requests.
+	AllowPastTrips bool
+	// MatrixConcurrency is number of travel matrix requests of single trip
+	// fetched at once, planner.DefaultMatrixConcurrency if not positive.
+	MatrixConcurrency int
+	// Tenants authenticate requests and provide their API keys, requests
+	// are not authenticated if nil.
+	Tenants *tenant.Registry
+	// AllowClientKeys lets requests that are not authenticated as any of
+	// Tenants use API key of the request.
+	AllowClientKeys bool
+	// Exceptions override opening hours of places in regions on given
+	// dates in every trip.
+	Exceptions []trip.RegionException
+}
+
 type service struct {
-	cacheTransport *httpcache.Transport
+	providers         provider.Factory
+	backends          provider.Backends
+	allowPastTrips    bool
+	matrixConcurrency int
+	exceptions        []trip.RegionException
+	ledger            *usage.Ledger
 }
 
-func NewService() Service {
+func NewService(config Config) Service {
 	return &service{
-		cacheTransport: httpcache.NewMemoryCacheTransport(),
+		providers:         config.Providers,
+		backends:          config.Backends,
+		allowPastTrips:    config.AllowPastTrips,
+		matrixConcurrency: config.MatrixConcurrency,
+		exceptions:        config.Exceptions,
+		ledger:            usage.NewLedger(),
 	}
 }
 
-func (s *service) TripPlan(ctx context.Context, tc trip.Configuration) (t trip.Trip, err error) {
-	if tc.APIKey == "" {
-		return trip.Trip{}, ErrAPIKeyEmpty
-	}
-
-	if tc.Mode == "" {
-		return trip.Trip{}, ErrModeEmpty
-	} else if !utils.StringIn(tc.Mode, trip.ModeOptions) {
-		return trip.Trip{}, ErrBadMode
-	}
-
-	if tc.TravelMode == "" {
-		tc.TravelMode = trip.TravelModeOptions[0]
-	} else if !utils.StringIn(tc.TravelMode, trip.TravelModeOptions) {
-		return trip.Trip{}, ErrBadTravelMode
-	}
-
-	var ts, te time.Time
-	var now = time.Now()
-
-	if tc.TripStart == "" {
-		return trip.Trip{}, ErrTripStartEmpty
-	} else if ts, err = time.Parse(time.RFC3339, tc.TripStart); err != nil {
-		return trip.Trip{}, ErrBadTimeFormat
-	} else if ts.Before(now) {
-		return trip.Trip{}, ErrBadTime
+func tenantName(ctx context.Context) string {
+	if t, ok := tenant.FromContext(ctx); ok {
+		return t.Name
 	}
+	return tenant.Anonymous
+}
 
-	if tc.TripEnd == "" {
-		return trip.Trip{}, ErrTripEndEmpty
-	} else if te, err = time.Parse(time.RFC3339, tc.TripEnd); err != nil {
-		return trip.Trip{}, ErrBadTimeFormat
-	} else if te.Before(now) {
-		return trip.Trip{}, ErrBadTime
+// Usage returns running totals of the tenant of the request or of every
+// tenant for admins and unauthenticated requests.
+func (s *service) Usage(ctx context.Context) (usage.Report, error) {
+	if t, ok := tenant.FromContext(ctx); ok && !t.Admin {
+		return s.ledger.Report(t.Name), nil
 	}
+	return s.ledger.Report(), nil
+}
 
-	if te.Before(ts) {
-		return trip.Trip{}, ErrEndBeforeStart
+func (s *service) TripPlan(ctx context.Context, tc trip.Configuration) (t trip.Trip, err error) {
+	ts, te, err := s.validate(&tc)
+	if err != nil {
+		return trip.Trip{}, err
 	}
-
-	var pLen int
-
-	if pLen = len(tc.PlacesConfiguration); pLen < 2 {
-		return trip.Trip{}, ErrNotEnoughPlaces
+	windows, err := dailyWindows(tc, ts, te)
+	if err != nil {
+		return trip.Trip{}, err
 	}
+	pLen := len(tc.PlacesConfiguration)
 
 	t = trip.Trip{
 		Places:     make([]*trip.Place, pLen),
 		TripStart:  ts,
 		TripEnd:    te,
-		TravelMode: maps.Mode(tc.TravelMode),
+		TravelMode: provider.TravelMode(tc.TravelMode),
+		Windows:    windows,
+		Breaks:     tc.Breaks,
 	}
 
-	c, err := maps.NewClient(maps.WithAPIKey(tc.APIKey), maps.WithHTTPClient(s.cacheTransport.Client()))
-
+	pr, err := s.providers(tc.APIKey)
 	if err != nil {
-		println("error 1")
 		return t, err
 	}
 
+	ctx, counter := usage.NewContext(ctx)
+	defer func() {
+		t.Usage = counter.Usage()
+		s.ledger.Add(tenantName(ctx), t.Usage)
+	}()
+
+	exceptions := append(append([]trip.RegionException{}, s.exceptions...), tc.Exceptions...)
+
+	// places left to resolve are cancelled as soon as one of them fails
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 	wg := sync.WaitGroup{}
 	wg.Add(len(tc.PlacesConfiguration))
 	errChan := make(chan error, len(tc.PlacesConfiguration))
+	fail := func(err error) {
+		errChan <- err
+		cancel()
+	}
 	for i, p := range tc.PlacesConfiguration {
 		go func(i int, place *trip.PlaceConfig) {
 			defer wg.Done()
-			config := mapstructure.DecoderConfig{ErrorUnused: true}
-			var placeID string
-			switch tc.Mode {
-			case "address":
-				config.Result = &trip.AddressDescription{}
-			case "name":
-				config.Result = &trip.NameDescription{}
-			case "id":
-				config.Result = &trip.PlaceIDDescription{}
-			default:
-				errChan <- ErrBadMode
-				return
-			}
-			decoder, err := mapstructure.NewDecoder(&config)
-			if err != nil {
-				errChan <- err
-				return
-			}
-			if err = decoder.Decode(place.Description); err != nil {
-				errChan <- ErrBadDescription{place}
-				return
-			}
-			if _, ok := config.Result.(trip.Description); ok {
-				place.Description = config.Result
-			} else {
-				errChan <- ErrBadDescription{place}
-				return
-			}
-			switch tc.Mode {
-			case "address":
-				if place.Description.(*trip.AddressDescription).IsEmpty() {
-					errChan <- ErrBadDescription{place}
-					return
-				}
-			case "name":
-				if place.Description.(*trip.NameDescription).Name == "" {
-					errChan <- ErrBadDescription{place}
-					return
-				}
-			case "id":
-				if place.Description.(*trip.PlaceIDDescription).PlaceID == "" {
-					errChan <- ErrBadDescription{place}
-					return
-				}
-			}
-			placeID, err = place.Description.(trip.Description).MapsPlaceID(s, c)
+			placeID, err := place.Description.(trip.Description).Resolve(ctx, pr)
 			switch err {
 			case nil:
 				break
 			case trip.ErrZeroResults:
-				errChan <- ErrDescriptionInaccurate{place}
+				fail(ErrDescriptionInaccurate{place})
 				return
 			default:
-				errChan <- err
+				fail(err)
 				return
 			}
 			t.Places[i] = &trip.Place{
@@ -217,6 +243,12 @@ func (s *service) TripPlan(ctx context.Context, tc trip.Configuration) (t trip.T
 				StayDuration: place.StayDuration,
 				Priority:     place.Priority,
 				PlaceID:      placeID,
+				Required:     place.Required,
+			}
+			t.Places[i].Earliest, t.Places[i].Latest, _ = place.VisitWindow()
+			if place.Appointment != nil {
+				w, _ := place.Appointment.Window()
+				t.Places[i].Appointment = &w
 			}
 			if t.Places[i].Priority > 10 {
 				t.Places[i].Priority = 10
@@ -228,22 +260,38 @@ func (s *service) TripPlan(ctx context.Context, tc trip.Configuration) (t trip.T
 			}
 			if place.Start {
 				if t.StartPlace != nil {
-					errChan <- ErrTwoStartPlaces
+					fail(ErrTwoStartPlaces)
 					return
 				}
 				t.StartPlace = t.Places[i]
 			}
 			if place.End {
 				if t.EndPlace != nil {
-					errChan <- ErrTwoEndPlaces
+					fail(ErrTwoEndPlaces)
 					return
 				}
 				t.EndPlace = t.Places[i]
 			}
-			err = t.Places[i].SetDetails(s, c, tc.Language)
-			if err != nil {
-				errChan <- err
-				return
+			if placeID != "" {
+				err = t.Places[i].SetDetails(ctx, pr, tc.Language)
+				if err != nil {
+					fail(err)
+					return
+				}
+			}
+			if d, ok := place.Description.(trip.Describer); ok {
+				d.Describe(t.Places[i])
+			}
+			if t.Places[i].Details.Location == nil {
+				t.Places[i].Details.Location = t.TripStart.Location()
+			}
+			t.Places[i].SetExceptions(exceptions, place.Exceptions)
+			if tc.HolidaySundayHours {
+				t.Places[i].SetHolidays()
+			}
+			if place.Lodging {
+				t.Places[i].SetLodging()
+				t.Lodging = t.Places[i]
 			}
 
 			errChan <- nil
@@ -256,41 +304,297 @@ func (s *service) TripPlan(ctx context.Context, tc trip.Configuration) (t trip.T
 			return t, err
 		}
 	}
+	for _, pr := range tc.Precedence {
+		t.Places[pr.After].Predecessors = append(t.Places[pr.After].Predecessors, pr.Before)
+	}
 
-	{
-		tswd := t.TripStart.Weekday()
-		h, m, _ := t.TripStart.Clock()
-		fh, fm := 23, 59
-
-		for _, p := range t.Places {
-			var o string
-			for wd, op := range p.Details.OpeningHoursPeriods {
-				if wd == tswd {
-					o = op.Open
-					break
-				}
+	if t.Windows == nil {
+		if o := earliestOpening(t.Places, t.TripStart); o.After(t.TripStart) {
+			t.TripStart = o
+		}
+	}
+
+	p := planner.NewPlanner(pr, &t, s.matrixConcurrency)
+	err = p.Evaluate(ctx)
+
+	if err != nil {
+		return t, err
+	}
+
+	return t, nil
+}
+
+// earliestOpening returns the earliest opening of places on the day of trip
+// start ts, each day in time zone of the place, or zero time if none of them
+// opens that day. Place opened the day before and still open at ts opens at ts.
+func earliestOpening(places []*trip.Place, ts time.Time) (earliest time.Time) {
+	for _, p := range places {
+		var o time.Time
+		day := ts.In(p.Details.Location)
+		if periods := p.Details.PeriodsOn(day); len(periods) > 0 {
+			o = clockOn(day, periods[0].Open)
+		}
+		// period opened the day before can still be open at trip start
+		before := day.AddDate(0, 0, -1)
+		for _, op := range p.Details.PeriodsOn(before) {
+			if clockOn(before.AddDate(0, 0, op.CloseDay), op.Close).After(ts) {
+				o = ts
 			}
-			if o != "" {
-				oh, _ := strconv.Atoi(o[:2])
-				om, _ := strconv.Atoi(o[2:])
-				if oh < fh || om < fm {
-					fh, fm = oh, om
-				}
+		}
+		if !o.IsZero() && (earliest.IsZero() || o.Before(earliest)) {
+			earliest = o
+		}
+	}
+	return earliest
+}
+
+// clockOn returns time of day given in 'hhmm' format on date of day, in its
+// time zone.
+func clockOn(day time.Time, hhmm string) time.Time {
+	hh, _ := strconv.Atoi(hhmm[:2])
+	mm, _ := strconv.Atoi(hhmm[2:])
+	y, m, d := day.Date()
+	return time.Date(y, m, d, hh, mm, 0, 0, day.Location())
+}
+
+// validate checks configuration of the trip, sets its defaults and decodes
+// place descriptions, it returns parsed trip start and end times.
+func (s *service) validate(tc *trip.Configuration) (ts, te time.Time, err error) {
+	if tc.Mode != "" && !utils.StringIn(tc.Mode, trip.ModeOptions) {
+		return ts, te, ErrBadMode
+	}
+
+	if tc.TravelMode == "" {
+		tc.TravelMode = trip.TravelModeOptions[0]
+	} else if !utils.StringIn(tc.TravelMode, trip.TravelModeOptions) {
+		return ts, te, ErrBadTravelMode
+	}
+
+	var now = time.Now()
+
+	if tc.TripStart == "" {
+		return ts, te, ErrTripStartEmpty
+	} else if ts, err = time.Parse(time.RFC3339, tc.TripStart); err != nil {
+		return ts, te, ErrBadTimeFormat
+	} else if ts.Before(now) && !s.allowPastTrips {
+		return ts, te, ErrBadTime
+	}
+
+	if tc.TripEnd == "" {
+		return ts, te, ErrTripEndEmpty
+	} else if te, err = time.Parse(time.RFC3339, tc.TripEnd); err != nil {
+		return ts, te, ErrBadTimeFormat
+	} else if te.Before(now) && !s.allowPastTrips {
+		return ts, te, ErrBadTime
+	}
+
+	if te.Before(ts) {
+		return ts, te, ErrEndBeforeStart
+	}
+
+	if len(tc.PlacesConfiguration) < 2 {
+		return ts, te, ErrNotEnoughPlaces
+	}
+
+	for _, place := range tc.PlacesConfiguration {
+		mode := place.Mode
+		if mode == "" {
+			mode = tc.Mode
+		}
+		if mode == "" {
+			return ts, te, ErrModeEmpty
+		} else if !utils.StringIn(mode, trip.ModeOptions) {
+			return ts, te, ErrBadMode
+		}
+		if err = decodeDescription(mode, place); err != nil {
+			return ts, te, err
+		}
+		if e, l, err := place.VisitWindow(); err != nil || (e != "" && l != "" && e >= l) {
+			return ts, te, ErrBadVisitWindow
+		}
+		if place.Appointment != nil {
+			w, err := place.Appointment.Window()
+			if err != nil || w.End.Before(ts) || w.Start.After(te) {
+				return ts, te, ErrBadAppointment
+			}
+		}
+	}
+
+	for i := range tc.Exceptions {
+		if err = tc.Exceptions[i].Normalize(); err != nil {
+			return ts, te, err
+		}
+	}
+	for _, place := range tc.PlacesConfiguration {
+		for i := range place.Exceptions {
+			if err = place.Exceptions[i].Normalize(); err != nil {
+				return ts, te, err
 			}
 		}
+	}
+
+	for i := range tc.Breaks {
+		if err = tc.Breaks[i].Normalize(); err != nil {
+			return ts, te, err
+		}
+	}
+
+	var lodgings, ends int
+	for _, place := range tc.PlacesConfiguration {
+		if place.Lodging {
+			lodgings++
+		}
+		if place.Start || place.End {
+			ends++
+		}
+	}
+	if lodgings > 1 {
+		return ts, te, ErrTwoLodgings
+	} else if lodgings == 1 && ends > 0 {
+		return ts, te, ErrLodgingStartEnd
+	}
+	return ts, te, checkPrecedence(tc)
+}
 
-		if fh > h {
-			t.TripStart = t.TripStart.Add(time.Duration(fh-h) * time.Hour)
-			t.TripStart = t.TripStart.Add(time.Duration(fm-m) * time.Minute)
+// checkPrecedence validates that precedence constraints refer to existing
+// places and can be satisfied together.
+func checkPrecedence(tc *trip.Configuration) error {
+	places := tc.PlacesConfiguration
+	successors := make(map[int][]int)
+	for _, pr := range tc.Precedence {
+		if pr.Before < 0 || pr.Before >= len(places) || pr.After < 0 || pr.After >= len(places) ||
+			pr.Before == pr.After {
+			return ErrBadPrecedence
+		}
+		before, after := places[pr.Before], places[pr.After]
+		if after.Start || after.End || after.Lodging || before.End {
+			return ErrPrecedenceImpossible
+		}
+		if before.Appointment != nil && after.Appointment != nil {
+			bw, _ := before.Appointment.Window()
+			aw, _ := after.Appointment.Window()
+			if !bw.Start.Before(aw.End) {
+				return ErrPrecedenceImpossible
+			}
 		}
+		successors[pr.Before] = append(successors[pr.Before], pr.After)
 	}
 
-	p := planner.NewPlanner(c, &t)
-	err = p.Evaluate()
+	// places are visited depth first, a place reached again while still
+	// being visited closes a cycle
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make([]int, len(places))
+	var visit func(i int) bool
+	visit = func(i int) bool {
+		switch state[i] {
+		case visiting:
+			return false
+		case done:
+			return true
+		}
+		state[i] = visiting
+		for _, j := range successors[i] {
+			if !visit(j) {
+				return false
+			}
+		}
+		state[i] = done
+		return true
+	}
+	for i := range places {
+		if !visit(i) {
+			return ErrPrecedenceCycle
+		}
+	}
+	return nil
+}
 
+// dailyWindows returns daily active windows of multi-day trip or nil if no
+// daily window is configured. Trip of several days has to have lodging to
+// spend nights at.
+func dailyWindows(tc trip.Configuration, ts, te time.Time) ([]trip.Window, error) {
+	if tc.DailyStart == "" && tc.DailyEnd == "" {
+		return nil, nil
+	}
+	clock := func(s string) string { return strings.ReplaceAll(s, ":", "") }
+	if tc.DailyStart == "" || tc.DailyEnd == "" || clock(tc.DailyStart) >= clock(tc.DailyEnd) {
+		return nil, ErrBadDailyWindow
+	}
+	windows, err := trip.DailyWindows(ts, te, tc.DailyStart, tc.DailyEnd)
 	if err != nil {
-		return t, err
+		return nil, ErrBadDailyWindow
+	} else if len(windows) == 0 {
+		return nil, ErrNoDailyWindow
 	}
+	if len(windows) > 1 {
+		for _, place := range tc.PlacesConfiguration {
+			if place.Lodging {
+				return windows, nil
+			}
+		}
+		return nil, ErrNoLodging
+	}
+	return windows, nil
+}
 
-	return t, nil
+// singleToList lets single object be given where list is expected, like one
+// opening period of a day.
+func singleToList(from, to reflect.Type, data interface{}) (interface{}, error) {
+	if to.Kind() == reflect.Slice && from.Kind() == reflect.Map {
+		return []interface{}{data}, nil
+	}
+	return data, nil
+}
+
+// decodeDescription replaces raw description of the place with Description
+// decoded in given mode and validates it.
+func decodeDescription(mode string, place *trip.PlaceConfig) error {
+	config := mapstructure.DecoderConfig{ErrorUnused: true, DecodeHook: singleToList}
+	switch mode {
+	case "address":
+		config.Result = &trip.AddressDescription{}
+	case "name":
+		config.Result = &trip.NameDescription{}
+	case "id":
+		config.Result = &trip.PlaceIDDescription{}
+	case "latlng":
+		config.Result = &trip.LatLngDescription{}
+	case "custom":
+		config.Result = &trip.CustomDescription{}
+	default:
+		return ErrBadMode
+	}
+	decoder, err := mapstructure.NewDecoder(&config)
+	if err != nil {
+		return err
+	}
+	if err = decoder.Decode(place.Description); err != nil {
+		return ErrBadDescription{place}
+	}
+	if _, ok := config.Result.(trip.Description); !ok {
+		return ErrBadDescription{place}
+	}
+	place.Description = config.Result
+
+	var valid bool
+	switch d := config.Result.(type) {
+	case *trip.AddressDescription:
+		valid = !d.IsEmpty()
+	case *trip.NameDescription:
+		valid = d.Name != ""
+	case *trip.PlaceIDDescription:
+		valid = d.PlaceID != ""
+	case *trip.LatLngDescription:
+		valid = d.IsValid()
+	case *trip.CustomDescription:
+		valid = d.IsValid()
+	}
+	if !valid {
+		return ErrBadDescription{place}
+	}
+	return nil
 }
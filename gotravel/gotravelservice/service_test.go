@@ -0,0 +1,622 @@
+package gotravelservice
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/provider"
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/provider/record"
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/trip"
+)
+
+// replayDir holds fixtures recorded for replayConfiguration, record them
+// again with -record when requests made by TripPlan change.
+const replayDir = "testdata/replay"
+
+func replayPlace(name, street, number string, priority, stay int) *trip.PlaceConfig {
+	return &trip.PlaceConfig{
+		Priority:     priority,
+		StayDuration: stay,
+		Description: map[string]interface{}{
+			"name":   name,
+			"street": street,
+			"number": number,
+			"city":   "Wrocław",
+		},
+	}
+}
+
+// replayConfiguration is a walking trip on Tuesday, when Panorama Racławicka
+// is closed.
+func replayConfiguration() trip.Configuration {
+	return trip.Configuration{
+		Mode:       "address",
+		TripStart:  "2024-06-04T09:00:00+02:00",
+		TripEnd:    "2024-06-04T17:00:00+02:00",
+		TravelMode: "walking",
+		PlacesConfiguration: []*trip.PlaceConfig{
+			replayPlace("Muzeum Narodowe", "plac Powstańców Warszawy", "5", 8, 60),
+			replayPlace("Hydropolis", "Na Grobli", "17", 10, 90),
+			replayPlace("Sky Tower", "Powstańców Śląskich", "95", 5, 30),
+			replayPlace("Panorama Racławicka", "Jana Ewangelisty Purkyniego", "11", 10, 45),
+		},
+	}
+}
+
+func TestTripPlanReplay(t *testing.T) {
+	s := NewService(Config{Providers: record.NewReplayFactory(replayDir), AllowPastTrips: true})
+
+	tr, err := s.TripPlan(context.Background(), replayConfiguration())
+	if err != nil {
+		t.Fatalf("TripPlan: %v", err)
+	}
+
+	visited := make(map[int]bool)
+	for _, i := range tr.Path {
+		visited[i] = true
+	}
+	for i, want := range []bool{true, true, true, false} {
+		if visited[i] != want {
+			t.Errorf("place %d visited = %v, want %v, path %v", i, visited[i], want, tr.Path)
+		}
+	}
+
+	start := time.Date(2024, time.June, 4, 9, 0, 0, 0, time.FixedZone("", 2*60*60))
+	end := start.Add(8 * time.Hour)
+	if tr.TripStart.Before(start) || tr.TripEnd.After(end) {
+		t.Errorf("trip %v - %v outside %v - %v", tr.TripStart, tr.TripEnd, start, end)
+	}
+	for _, p := range tr.Places {
+		if !visited[p.Index] {
+			continue
+		}
+		open := time.Date(2024, time.June, 4, 10, 0, 0, 0, p.Details.Location)
+		if p.Arrival.Before(open) {
+			t.Errorf("%s visited at %v before opening", p.Details.Name, p.Arrival)
+		}
+	}
+}
+
+func TestTripPlanReplayNotRecorded(t *testing.T) {
+	s := NewService(Config{Providers: record.NewReplayFactory(replayDir), AllowPastTrips: true})
+
+	tc := replayConfiguration()
+	tc.PlacesConfiguration[3] = replayPlace("Ogród Japoński", "Adama Mickiewicza", "1", 5, 30)
+	_, err := s.TripPlan(context.Background(), tc)
+	var notFound record.ErrFixtureNotFound
+	if !errors.As(err, &notFound) {
+		t.Fatalf("TripPlan error = %v, want ErrFixtureNotFound", err)
+	}
+}
+
+func TestCheckPrecedence(t *testing.T) {
+	appointment := func(at string) *trip.PlaceConfig {
+		return &trip.PlaceConfig{Appointment: &trip.AppointmentConfig{At: at, Early: 15}}
+	}
+	// order returns precedence of every pair of place indexes
+	order := func(pairs ...int) (precedence []trip.Precedence) {
+		for i := 0; i < len(pairs); i += 2 {
+			precedence = append(precedence, trip.Precedence{Before: pairs[i], After: pairs[i+1]})
+		}
+		return precedence
+	}
+	places := func(n int) []*trip.PlaceConfig {
+		pcs := make([]*trip.PlaceConfig, n)
+		for i := range pcs {
+			pcs[i] = &trip.PlaceConfig{}
+		}
+		return pcs
+	}
+	tests := []struct {
+		name       string
+		places     []*trip.PlaceConfig
+		precedence []trip.Precedence
+		err        error
+	}{
+		{
+			name:       "chain",
+			places:     places(4),
+			precedence: order(0, 1, 1, 2, 0, 3, 3, 2),
+		},
+		{
+			name:       "index out of places",
+			places:     places(2),
+			precedence: order(0, 2),
+			err:        ErrBadPrecedence,
+		},
+		{
+			name:       "same place",
+			places:     places(2),
+			precedence: order(1, 1),
+			err:        ErrBadPrecedence,
+		},
+		{
+			name:       "cycle",
+			places:     places(4),
+			precedence: order(0, 1, 1, 2, 2, 3, 3, 1),
+			err:        ErrPrecedenceCycle,
+		},
+		{
+			name:       "two places cycle",
+			places:     places(2),
+			precedence: order(0, 1, 1, 0),
+			err:        ErrPrecedenceCycle,
+		},
+		{
+			name:       "start follows",
+			places:     []*trip.PlaceConfig{{}, {Start: true}},
+			precedence: order(0, 1),
+			err:        ErrPrecedenceImpossible,
+		},
+		{
+			name:       "lodging follows",
+			places:     []*trip.PlaceConfig{{}, {Lodging: true}},
+			precedence: order(0, 1),
+			err:        ErrPrecedenceImpossible,
+		},
+		{
+			name:       "end follows",
+			places:     []*trip.PlaceConfig{{}, {End: true}},
+			precedence: order(0, 1),
+			err:        ErrPrecedenceImpossible,
+		},
+		{
+			name:       "end precedes",
+			places:     []*trip.PlaceConfig{{End: true}, {}},
+			precedence: order(0, 1),
+			err:        ErrPrecedenceImpossible,
+		},
+		{
+			name:       "appointments in order",
+			places:     []*trip.PlaceConfig{appointment("2024-06-04T10:00:00Z"), appointment("2024-06-04T12:00:00Z")},
+			precedence: order(0, 1),
+		},
+		{
+			name:       "appointments out of order",
+			places:     []*trip.PlaceConfig{appointment("2024-06-04T12:00:00Z"), appointment("2024-06-04T10:00:00Z")},
+			precedence: order(0, 1),
+			err:        ErrPrecedenceImpossible,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tc := &trip.Configuration{PlacesConfiguration: tt.places, Precedence: tt.precedence}
+			if err := checkPrecedence(tc); err != tt.err {
+				t.Errorf("checkPrecedence error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestDailyWindows(t *testing.T) {
+	tests := []struct {
+		name    string
+		end     string
+		lodging bool
+		days    int
+		err     error
+	}{
+		{name: "one day", end: "2024-06-04T20:00:00+02:00", days: 1},
+		{name: "several days with lodging", end: "2024-06-06T20:00:00+02:00", lodging: true, days: 3},
+		{name: "several days without lodging", end: "2024-06-06T20:00:00+02:00", err: ErrNoLodging},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tc := trip.Configuration{
+				DailyStart:          "09:00",
+				DailyEnd:            "17:00",
+				PlacesConfiguration: []*trip.PlaceConfig{{}, {Lodging: tt.lodging}},
+			}
+			ts, _ := time.Parse(time.RFC3339, "2024-06-04T08:00:00+02:00")
+			te, _ := time.Parse(time.RFC3339, tt.end)
+			windows, err := dailyWindows(tc, ts, te)
+			if err != tt.err || len(windows) != tt.days {
+				t.Errorf("dailyWindows = %d windows, %v, want %d, %v", len(windows), err, tt.days, tt.err)
+			}
+		})
+	}
+}
+
+func TestEarliestOpening(t *testing.T) {
+	cest := time.FixedZone("+2", 2*60*60)
+	// place returns place in loc opened every day in given periods
+	place := func(loc *time.Location, periods ...trip.OpeningHours) *trip.Place {
+		hours := make(map[time.Weekday][]trip.OpeningHours, 7)
+		for d := time.Sunday; d <= time.Saturday; d++ {
+			hours[d] = periods
+		}
+		return &trip.Place{Details: trip.PlaceDetails{OpeningHoursPeriods: hours, Location: loc}}
+	}
+	at := func(hh, mm int) time.Time {
+		return time.Date(2024, time.June, 4, hh, mm, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name   string
+		places []*trip.Place
+		start  time.Time
+		want   time.Time
+	}{
+		{
+			name: "earliest of places",
+			places: []*trip.Place{
+				place(time.UTC, trip.OpeningHours{Open: "1100", Close: "1800"}),
+				place(time.UTC, trip.OpeningHours{Open: "1045", Close: "1800"}),
+			},
+			start: at(9, 0),
+			want:  at(10, 45),
+		},
+		{
+			name:   "time zone of place",
+			places: []*trip.Place{place(cest, trip.OpeningHours{Open: "1000", Close: "1800"})},
+			start:  at(7, 0),
+			want:   at(8, 0),
+		},
+		{
+			name:   "open before trip start",
+			places: []*trip.Place{place(time.UTC, trip.OpeningHours{Open: "0800", Close: "1800"})},
+			start:  at(9, 0),
+			want:   at(8, 0),
+		},
+		{
+			name:   "opened the day before",
+			places: []*trip.Place{place(time.UTC, trip.OpeningHours{Open: "2000", Close: "0200", CloseDay: 1})},
+			start:  at(1, 0),
+			want:   at(1, 0),
+		},
+		{
+			name: "closed the day before in time zone of place",
+			places: []*trip.Place{
+				place(cest, trip.OpeningHours{Open: "2000", Close: "0200", CloseDay: 1}),
+				place(time.UTC, trip.OpeningHours{Open: "1000", Close: "1800"}),
+			},
+			start: at(1, 0),
+			want:  at(10, 0),
+		},
+		{
+			name:   "closed all day",
+			places: []*trip.Place{place(time.UTC)},
+			start:  at(9, 0),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := earliestOpening(tt.places, tt.start); !got.Equal(tt.want) {
+				t.Errorf("earliestOpening = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeDescription(t *testing.T) {
+	lat, lng := 51.1097, 17.0319
+	tests := []struct {
+		name        string
+		mode        string
+		description interface{}
+		want        trip.Description
+	}{
+		{
+			name:        "latlng",
+			mode:        "latlng",
+			description: map[string]interface{}{"lat": lat, "lng": lng},
+			want:        &trip.LatLngDescription{Lat: &lat, Lng: &lng},
+		},
+		{
+			name:        "latlng with label and lookup",
+			mode:        "latlng",
+			description: map[string]interface{}{"lat": lat, "lng": lng, "label": "Meeting point", "lookup": true},
+			want:        &trip.LatLngDescription{Lat: &lat, Lng: &lng, Label: "Meeting point", Lookup: true},
+		},
+		{
+			name:        "latlng without lng",
+			mode:        "latlng",
+			description: map[string]interface{}{"lat": lat},
+		},
+		{
+			name:        "latlng out of range",
+			mode:        "latlng",
+			description: map[string]interface{}{"lat": 91.0, "lng": lng},
+		},
+		{
+			name:        "latlng with unknown field",
+			mode:        "latlng",
+			description: map[string]interface{}{"lat": lat, "lng": lng, "name": "Meeting point"},
+		},
+		{
+			name:        "latlng as text",
+			mode:        "latlng",
+			description: "51.1097,17.0319",
+		},
+		{
+			name: "custom with single period a day",
+			mode: "custom",
+			description: map[string]interface{}{
+				"name": "Pop-up market", "lat": lat, "lng": lng, "timeZone": "+02:00",
+				"openingHours": map[string]interface{}{
+					"tue": map[string]interface{}{"open": "12:00", "close": "14:00"},
+					"3": []interface{}{
+						map[string]interface{}{"open": "10:00", "close": "12:00"},
+						map[string]interface{}{"open": "16:00", "close": "18:00"},
+					},
+				},
+			},
+			want: &trip.CustomDescription{
+				Name: "Pop-up market", Lat: &lat, Lng: &lng, TimeZone: "+02:00",
+				OpeningHours: map[string][]trip.OpeningHours{
+					"tue": {{Open: "12:00", Close: "14:00"}},
+					"3":   {{Open: "10:00", Close: "12:00"}, {Open: "16:00", Close: "18:00"}},
+				},
+			},
+		},
+		{
+			name:        "custom without name",
+			mode:        "custom",
+			description: map[string]interface{}{"lat": lat, "lng": lng},
+		},
+		{
+			name: "custom with unknown weekday",
+			mode: "custom",
+			description: map[string]interface{}{
+				"name": "Pop-up market", "lat": lat, "lng": lng,
+				"openingHours": map[string]interface{}{"someday": map[string]interface{}{"open": "12:00", "close": "14:00"}},
+			},
+		},
+		{
+			name: "custom with bad hours",
+			mode: "custom",
+			description: map[string]interface{}{
+				"name": "Pop-up market", "lat": lat, "lng": lng,
+				"openingHours": map[string]interface{}{"tue": map[string]interface{}{"open": "noon", "close": "14:00"}},
+			},
+		},
+		{
+			name:        "custom with unknown time zone",
+			mode:        "custom",
+			description: map[string]interface{}{"name": "Pop-up market", "lat": lat, "lng": lng, "timeZone": "Mars/Olympus"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pc := &trip.PlaceConfig{Description: tt.description}
+			err := decodeDescription(tt.mode, pc)
+			if tt.want == nil {
+				if _, ok := err.(ErrBadDescription); !ok {
+					t.Errorf("decodeDescription error = %v, want ErrBadDescription", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeDescription: %v", err)
+			}
+			if !reflect.DeepEqual(pc.Description, tt.want) {
+				t.Errorf("decoded description %#v, want %#v", pc.Description, tt.want)
+			}
+		})
+	}
+}
+
+// stubProvider resolves every query to itself and returns details of known
+// place IDs, travel between any two places takes 15 minutes. It records
+// calls made to it.
+type stubProvider struct {
+	details map[string]provider.Details
+
+	mu       sync.Mutex
+	queries  []string
+	fetched  []string
+	requests []provider.MatrixRequest
+}
+
+func (p *stubProvider) factory(string) (provider.Provider, error) {
+	return p, nil
+}
+
+func (p *stubProvider) PlaceID(_ context.Context, query string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.queries = append(p.queries, query)
+	return query, nil
+}
+
+func (p *stubProvider) PlaceIDAt(_ context.Context, ll provider.LatLng) (string, error) {
+	return p.PlaceID(context.Background(), ll.String())
+}
+
+func (p *stubProvider) PlaceDetails(_ context.Context, placeID string, _ string) (provider.Details, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.fetched = append(p.fetched, placeID)
+	d, ok := p.details[placeID]
+	if !ok {
+		return provider.Details{}, provider.ErrZeroResults
+	}
+	return d, nil
+}
+
+func (p *stubProvider) DistanceMatrix(_ context.Context, r provider.MatrixRequest) (provider.Matrix, error) {
+	p.mu.Lock()
+	p.requests = append(p.requests, r)
+	p.mu.Unlock()
+	var m provider.Matrix
+	for range r.Origins {
+		row := make([]provider.MatrixElement, len(r.Destinations))
+		for j := range row {
+			row[j] = provider.MatrixElement{OK: true, Duration: 15 * time.Minute, Distance: 1000}
+		}
+		m.Rows = append(m.Rows, row)
+	}
+	return m, nil
+}
+
+func (p *stubProvider) MatrixLimits(provider.TravelMode) provider.MatrixLimits {
+	return provider.MatrixLimits{}
+}
+
+// customPlace returns configuration of custom place at coordinates, open on
+// Tuesdays between open and close if given.
+func customPlace(name string, lat, lng float64, open, close string) *trip.PlaceConfig {
+	d := map[string]interface{}{"name": name, "lat": lat, "lng": lng, "timeZone": "+02:00"}
+	if open != "" {
+		d["openingHours"] = map[string]interface{}{"tuesday": map[string]interface{}{"open": open, "close": close}}
+	}
+	return &trip.PlaceConfig{Mode: "custom", Description: d, StayDuration: 60, Priority: 5}
+}
+
+func TestTripPlanCustomPlaces(t *testing.T) {
+	stub := &stubProvider{}
+	s := NewService(Config{Providers: stub.factory, AllowPastTrips: true})
+	tr, err := s.TripPlan(context.Background(), trip.Configuration{
+		TripStart: "2024-06-04T09:00:00+02:00",
+		TripEnd:   "2024-06-04T17:00:00+02:00",
+		PlacesConfiguration: []*trip.PlaceConfig{
+			customPlace("Friend's flat", 51.1, 17.0, "", ""),
+			customPlace("Pop-up market", 51.11, 17.03, "12:00", "14:00"),
+		},
+	})
+	if err != nil {
+		t.Fatalf("TripPlan: %v", err)
+	}
+
+	if len(stub.queries) > 0 || len(stub.fetched) > 0 {
+		t.Errorf("custom places looked up with queries %v and details of %v", stub.queries, stub.fetched)
+	}
+	market := tr.Places[1]
+	if market.Details.Name != "Pop-up market" || market.Details.Coordinates != (provider.LatLng{Lat: 51.11, Lng: 17.03}) {
+		t.Errorf("custom place details %+v, want ones from the request", market.Details)
+	}
+	open := time.Date(2024, time.June, 4, 12, 0, 0, 0, time.FixedZone("+2", 2*60*60))
+	if market.Departure.IsZero() || market.Departure.Add(-time.Hour).Before(open) || market.Departure.After(open.Add(2*time.Hour)) {
+		t.Errorf("custom place left at %v, want visit between its opening hours", market.Departure)
+	}
+	for _, r := range stub.requests {
+		for i, w := range r.Origins {
+			if w.Address != "" || w.LatLng == nil || *w.LatLng != tr.Places[i].Details.Coordinates {
+				t.Errorf("custom place %d requested as %+v, want its exact coordinates", i, w)
+			}
+		}
+	}
+}
+
+func TestValidateModes(t *testing.T) {
+	tests := []struct {
+		name       string
+		mode       string
+		travelMode string
+		modes      [2]string
+		err        error
+	}{
+		{name: "default mode", mode: "name"},
+		{name: "mode of every place", modes: [2]string{"name", "id"}},
+		{name: "mode overriding default", mode: "id", modes: [2]string{"name", ""}},
+		{name: "no mode", modes: [2]string{"name", ""}, err: ErrModeEmpty},
+		{name: "bad mode of place", mode: "name", modes: [2]string{"", "coordinates"}, err: ErrBadMode},
+		{name: "bad default mode", mode: "coordinates", modes: [2]string{"name", "name"}, err: ErrBadMode},
+		{name: "bad travel mode", mode: "name", travelMode: "flying", err: ErrBadTravelMode},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tc := trip.Configuration{
+				Mode:       tt.mode,
+				TravelMode: tt.travelMode,
+				TripStart:  "2024-06-04T09:00:00+02:00",
+				TripEnd:    "2024-06-04T17:00:00+02:00",
+			}
+			for _, mode := range tt.modes {
+				d := map[string]interface{}{"name": "Hydropolis"}
+				if mode == "id" || (mode == "" && tt.mode == "id") {
+					d = map[string]interface{}{"placeId": "hydropolis"}
+				}
+				tc.PlacesConfiguration = append(tc.PlacesConfiguration, &trip.PlaceConfig{Mode: mode, Description: d})
+			}
+			s := &service{allowPastTrips: true}
+			if _, _, err := s.validate(&tc); err != tt.err {
+				t.Errorf("validate error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestValidateExceptions(t *testing.T) {
+	exception := trip.Exception{Date: "2024-06-04", Hours: []trip.OpeningHours{{Open: "12:00", Close: "16:00"}}}
+	tests := []struct {
+		name   string
+		region string
+		own    trip.Exception
+		err    error
+	}{
+		{name: "region and own", region: "Wrocław", own: exception},
+		{name: "no region", region: "", err: ErrBadException},
+		{name: "blank region", region: " ", err: ErrBadException},
+		{name: "bad own date", region: "Wrocław", own: trip.Exception{Date: "04.06.2024"}, err: ErrBadException},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tc := trip.Configuration{
+				Mode:       "name",
+				TripStart:  "2024-06-04T09:00:00+02:00",
+				TripEnd:    "2024-06-04T17:00:00+02:00",
+				Exceptions: []trip.RegionException{{Region: tt.region, Exception: exception}},
+			}
+			for i := 0; i < 2; i++ {
+				tc.PlacesConfiguration = append(tc.PlacesConfiguration, &trip.PlaceConfig{
+					Description: map[string]interface{}{"name": "Hydropolis"},
+				})
+			}
+			if tt.own.Date != "" {
+				tc.PlacesConfiguration[0].Exceptions = []trip.Exception{tt.own}
+			}
+			s := &service{allowPastTrips: true}
+			if _, _, err := s.validate(&tc); err != tt.err {
+				t.Errorf("validate error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestTripPlanMixedModes(t *testing.T) {
+	always := []provider.Period{{Open: provider.PeriodTime{Day: time.Sunday, Time: "0000"}}}
+	stub := &stubProvider{details: map[string]provider.Details{
+		"hotel":      {Name: "Hotel", FormattedAddress: "Kazimierza Wielkiego 45, Wrocław", Periods: always},
+		"Hydropolis": {Name: "Hydropolis", FormattedAddress: "Na Grobli 17, Wrocław", Periods: always},
+	}}
+	s := NewService(Config{Providers: stub.factory, AllowPastTrips: true})
+	tr, err := s.TripPlan(context.Background(), trip.Configuration{
+		Mode:      "id",
+		TripStart: "2024-06-04T09:00:00+02:00",
+		TripEnd:   "2024-06-04T17:00:00+02:00",
+		PlacesConfiguration: []*trip.PlaceConfig{
+			{Description: map[string]interface{}{"placeId": "hotel"}},
+			{Mode: "name", Description: map[string]interface{}{"name": "Hydropolis"}, StayDuration: 60},
+			{Mode: "latlng", Description: map[string]interface{}{"lat": 51.1, "lng": 17.0, "label": "Meeting point"}},
+			customPlace("Pop-up market", 51.11, 17.03, "", ""),
+		},
+	})
+	if err != nil {
+		t.Fatalf("TripPlan: %v", err)
+	}
+
+	if !reflect.DeepEqual(stub.queries, []string{"Hydropolis"}) {
+		t.Errorf("queried %v, want only the place described by name", stub.queries)
+	}
+	if len(stub.fetched) != 2 {
+		t.Errorf("details of %v fetched, want of the hotel and Hydropolis only", stub.fetched)
+	}
+	for i, name := range []string{"Hotel", "Hydropolis", "Meeting point", "Pop-up market"} {
+		if got := tr.Places[i].Details.Name; got != name {
+			t.Errorf("place %d named %q, want %q", i, got, name)
+		}
+	}
+	// places found by provider are requested by address, the others by
+	// exact coordinates only
+	for _, r := range stub.requests {
+		for i, w := range r.Origins {
+			if pinned := i >= 2; pinned != (w.Address == "") {
+				t.Errorf("place %d requested as %+v", i, w)
+			}
+		}
+	}
+}
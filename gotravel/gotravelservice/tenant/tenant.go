@@ -0,0 +1,128 @@
+// Package tenant implements server side credentials of clients authenticated
+// with bearer tokens.
+package tenant
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+var ErrUnauthorized = errors.New("request must contain valid tenant token as 'Authorization: Bearer <token>' header")
+
+type ErrBadConfig struct {
+	Path string
+	Err  error
+}
+
+func (err ErrBadConfig) Error() string {
+	return fmt.Sprintf("bad tenants configuration %s: %v", err.Path, err.Err)
+}
+
+// Anonymous is the name under which usage of unauthenticated requests is
+// reported, no tenant can have it.
+const Anonymous = "anonymous"
+
+// Tenant is a client of the service holding provider credentials.
+type Tenant struct {
+	Name  string `json:"name"`
+	Token string `json:"token"`
+	// APIKey is Google Maps API key used for tenant's requests.
+	APIKey string `json:"apiKey"`
+	// AllowClientKey lets tenant's requests use 'apiKey' of the request
+	// when APIKey is empty.
+	AllowClientKey bool `json:"allowClientKey"`
+	// Admin tenants can see usage of all tenants.
+	Admin bool `json:"admin"`
+}
+
+// Registry authenticates tenants by their tokens.
+type Registry struct {
+	tenants []*Tenant
+}
+
+// Load reads JSON file with list of tenants. Values of tokens and API keys
+// can refer to environment variables as $VAR or ${VAR}.
+func Load(path string) (*Registry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var tenants []*Tenant
+	if err = json.Unmarshal(data, &tenants); err != nil {
+		return nil, ErrBadConfig{path, err}
+	}
+	tokens := make(map[string]bool, len(tenants))
+	for _, t := range tenants {
+		t.Token = os.ExpandEnv(t.Token)
+		t.APIKey = os.ExpandEnv(t.APIKey)
+		if t.Name == "" || t.Token == "" {
+			return nil, ErrBadConfig{path, errors.New("every tenant must have name and token")}
+		}
+		if t.Name == Anonymous {
+			return nil, ErrBadConfig{path, fmt.Errorf("tenant name %q is reserved for unauthenticated requests", Anonymous)}
+		}
+		if tokens[t.Token] {
+			return nil, ErrBadConfig{path, fmt.Errorf("token of tenant %q is not unique", t.Name)}
+		}
+		tokens[t.Token] = true
+	}
+	return &Registry{tenants: tenants}, nil
+}
+
+// Lookup returns tenant authenticated by token. Token is compared with
+// tokens of all tenants in constant time, so that timing doesn't reveal them.
+func (r *Registry) Lookup(token string) (found *Tenant, ok bool) {
+	if r == nil || token == "" {
+		return nil, false
+	}
+	for _, t := range r.tenants {
+		if subtle.ConstantTimeCompare([]byte(t.Token), []byte(token)) == 1 {
+			found, ok = t, true
+		}
+	}
+	return found, ok
+}
+
+type contextKey int
+
+const (
+	tokenKey contextKey = iota
+	tenantKey
+)
+
+// ContextWithToken returns context carrying token sent by the client.
+func ContextWithToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, tokenKey, token)
+}
+
+// TokenFromContext returns token sent by the client or empty string.
+func TokenFromContext(ctx context.Context) string {
+	token, _ := ctx.Value(tokenKey).(string)
+	return token
+}
+
+// NewContext returns context carrying authenticated tenant.
+func NewContext(ctx context.Context, t *Tenant) context.Context {
+	return context.WithValue(ctx, tenantKey, t)
+}
+
+// FromContext returns tenant authenticated for the request, if any.
+func FromContext(ctx context.Context) (*Tenant, bool) {
+	t, ok := ctx.Value(tenantKey).(*Tenant)
+	return t, ok
+}
+
+// Redact returns last characters of secret, so that it can be told apart in
+// logs without being revealed.
+func Redact(secret string) string {
+	if secret == "" {
+		return ""
+	} else if len(secret) < 16 {
+		return "[redacted]"
+	}
+	return "..." + secret[len(secret)-4:]
+}
@@ -0,0 +1,73 @@
+package tenant
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTenants(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tenants.json")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadAndLookup(t *testing.T) {
+	t.Setenv("TEST_TENANT_TOKEN", "token-of-beta")
+	r, err := Load(writeTenants(t, `[
+		{"name": "alpha", "token": "token-of-alpha", "apiKey": "key-a"},
+		{"name": "beta", "token": "${TEST_TENANT_TOKEN}"}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		token string
+		name  string
+		ok    bool
+	}{
+		{"token-of-alpha", "alpha", true},
+		{"token-of-beta", "beta", true},
+		{"token-of-alph", "", false},
+		{"token-of-alpha ", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := r.Lookup(tt.token)
+		if ok != tt.ok || (ok && got.Name != tt.name) {
+			t.Errorf("Lookup(%q) = %v, %v, want %q, %v", tt.token, got, ok, tt.name, tt.ok)
+		}
+	}
+
+	var nilRegistry *Registry
+	if _, ok := nilRegistry.Lookup("token-of-alpha"); ok {
+		t.Error("nil registry authenticated token")
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"not a list", `{"name": "alpha"}`},
+		{"no token", `[{"name": "alpha"}]`},
+		{"no name", `[{"token": "token-of-alpha"}]`},
+		{"reserved name", `[{"name": "anonymous", "token": "token-of-alpha"}]`},
+		{"duplicate token", `[{"name": "alpha", "token": "t"}, {"name": "beta", "token": "t"}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeTenants(t, tt.content))
+			var bad ErrBadConfig
+			if !errors.As(err, &bad) {
+				t.Errorf("Load error = %v, want ErrBadConfig", err)
+			}
+		})
+	}
+}
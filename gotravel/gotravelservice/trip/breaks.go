@@ -0,0 +1,57 @@
+package trip
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+var ErrBadBreak = errors.New("break must have positive duration that fits between its start and end " +
+	"given in 'hh:mm' format")
+
+// Break is rest of Duration minutes taken between visits, at times of day
+// between Start and End.
+type Break struct {
+	Name     string `json:"name,omitempty"`
+	Duration int    `json:"duration"`
+	Start    string `json:"start"`
+	End      string `json:"end"`
+}
+
+// Normalize validates break and converts its times to 'hhmm' format.
+func (b *Break) Normalize() error {
+	b.Start = strings.ReplaceAll(b.Start, ":", "")
+	b.End = strings.ReplaceAll(b.End, ":", "")
+	sh, sm, err := parseClock(b.Start)
+	if err != nil {
+		return ErrBadBreak
+	}
+	eh, em, err := parseClock(b.End)
+	if err != nil {
+		return ErrBadBreak
+	}
+	if b.Duration <= 0 || sh*60+sm+b.Duration > eh*60+em {
+		return ErrBadBreak
+	}
+	if b.Name == "" {
+		b.Name = "Break"
+	}
+	return nil
+}
+
+// TakenBreak is break placed in the trip.
+type TakenBreak struct {
+	Name  string    `json:"name"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// AddBreak adds step of taking break at place the path is at.
+func (p *Path) AddBreak(at int, b TakenBreak) {
+	p.Steps = append(p.Steps, Step{
+		From:     at,
+		To:       at,
+		Duration: b.End.Sub(b.Start) / time.Minute,
+		Break:    &b,
+	})
+}
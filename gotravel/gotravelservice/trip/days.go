@@ -0,0 +1,155 @@
+package trip
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrBadClock = errors.New("time of day must be provided in 'hh:mm' format")
+
+// Window is time range in which places are visited.
+type Window struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// Day is part of multi-day trip visited in one daily window.
+type Day struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Path  []int     `json:"path"`
+	Steps []Step    `json:"steps"`
+}
+
+// DailyWindows returns windows between dailyStart and dailyEnd, given in
+// "hh:mm" format, of every day of the trip, clipped to trip start and end
+// times. Days are counted in time zone of trip start.
+func DailyWindows(tripStart, tripEnd time.Time, dailyStart, dailyEnd string) ([]Window, error) {
+	sh, sm, err := parseClock(dailyStart)
+	if err != nil {
+		return nil, err
+	}
+	eh, em, err := parseClock(dailyEnd)
+	if err != nil {
+		return nil, err
+	}
+	var windows []Window
+	y, m, d := tripStart.Date()
+	for day := time.Date(y, m, d, 0, 0, 0, 0, tripStart.Location()); day.Before(tripEnd); day = day.AddDate(0, 0, 1) {
+		y, m, d := day.Date()
+		w := Window{
+			Start: time.Date(y, m, d, sh, sm, 0, 0, day.Location()),
+			End:   time.Date(y, m, d, eh, em, 0, 0, day.Location()),
+		}
+		if w.Start.Before(tripStart) {
+			w.Start = tripStart
+		}
+		if w.End.After(tripEnd) {
+			w.End = tripEnd
+		}
+		if w.Start.Before(w.End) {
+			windows = append(windows, w)
+		}
+	}
+	return windows, nil
+}
+
+// ActiveWindows returns daily windows of multi-day trip or the whole trip as
+// single window.
+func (t *Trip) ActiveWindows() []Window {
+	if t.Windows != nil {
+		return t.Windows
+	}
+	return []Window{{Start: t.TripStart, End: t.TripEnd}}
+}
+
+// SetLodging makes place the lodging of the trip, it has no stay, priority
+// or appointment of its own and is always open.
+func (p *Place) SetLodging() {
+	p.StayDuration = 0
+	p.Priority = 0
+	p.Appointment = nil
+	p.Details.PermanentlyClosed = false
+	p.Details.OpeningHoursPeriods = alwaysOpen()
+	p.Details.Exceptions = nil
+	p.Details.Holidays = nil
+}
+
+// JoinPaths returns path going through all given paths one after another.
+func JoinPaths(paths ...Path) Path {
+	if len(paths) == 1 {
+		return paths[0]
+	}
+	joined := NewPath(0, false)
+	for _, p := range paths {
+		joined.path = append(joined.path, p.Path()...)
+		joined.Steps = append(joined.Steps, p.Steps...)
+	}
+	joined.len = len(joined.path)
+	return joined
+}
+
+func (t *Trip) createDaysSchedule() {
+	var sStrings []string
+	for i, day := range t.Days {
+		sStrings = append(sStrings, fmt.Sprintf("Day %d, %s", i+1, day.Start.Format("Mon Jan 2")))
+		if len(day.Steps) == 0 {
+			sStrings = append(sStrings, "no visits")
+			continue
+		}
+		// place of a break is listed once, before the break
+		listed := -1
+		for _, s := range day.Steps {
+			if s.From != listed {
+				sStrings = append(sStrings, t.visitString(s.From, day.Start))
+				listed = s.From
+			}
+			if s.Break != nil {
+				sStrings = append(sStrings, fmt.Sprintf(
+					"[%s - %s] %s",
+					s.Break.Start.Format("15:04"),
+					s.Break.End.Format("15:04"),
+					s.Break.Name))
+			}
+		}
+		last := day.Steps[len(day.Steps)-1].To
+		if t.Lodging != nil && last == t.Lodging.Index {
+			sStrings = append(sStrings, fmt.Sprintf(
+				"[%s] %s, %s",
+				day.End.Format("15:04"),
+				t.Lodging.Details.Name,
+				t.Lodging.Details.FormattedAddress))
+		} else if last != listed {
+			sStrings = append(sStrings, t.visitString(last, day.Start))
+		}
+	}
+	t.Schedule = strings.Join(sStrings, "\n")
+}
+
+// visitString describes visit of i-th place of the trip, lodging is left at
+// the start of the day.
+func (t *Trip) visitString(i int, dayStart time.Time) string {
+	p := t.Places[i]
+	if t.Lodging != nil && i == t.Lodging.Index {
+		return fmt.Sprintf("[%s] %s, %s", dayStart.Format("15:04"), p.Details.Name, p.Details.FormattedAddress)
+	}
+	return fmt.Sprintf(
+		"[%s - %s] %s, %s",
+		p.Arrival.Format("15:04"),
+		p.Departure.Format("15:04"),
+		p.Details.Name,
+		p.Details.FormattedAddress)
+}
+
+func parseClock(s string) (hh, mm int, err error) {
+	s = strings.ReplaceAll(s, ":", "")
+	if !isHHMM(s) {
+		return 0, 0, ErrBadClock
+	}
+	hh, _ = strconv.Atoi(s[:2])
+	mm, _ = strconv.Atoi(s[2:])
+	return hh, mm, nil
+}
@@ -0,0 +1,66 @@
+package trip
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDailyWindows(t *testing.T) {
+	cest := time.FixedZone("+2", 2*60*60)
+	at := func(day, hh, mm int) time.Time {
+		return time.Date(2024, time.June, day, hh, mm, 0, 0, cest)
+	}
+	tests := []struct {
+		name       string
+		start, end time.Time
+		daily      [2]string
+		want       []Window
+		err        error
+	}{
+		{
+			name:  "one day clipped",
+			start: at(4, 10, 30), end: at(4, 16, 0),
+			daily: [2]string{"09:00", "17:00"},
+			want:  []Window{{at(4, 10, 30), at(4, 16, 0)}},
+		},
+		{
+			name:  "clipped first and last day",
+			start: at(4, 13, 0), end: at(6, 11, 0),
+			daily: [2]string{"09:00", "17:00"},
+			want:  []Window{{at(4, 13, 0), at(4, 17, 0)}, {at(5, 9, 0), at(5, 17, 0)}, {at(6, 9, 0), at(6, 11, 0)}},
+		},
+		{
+			name:  "days outside of trip time",
+			start: at(4, 18, 0), end: at(6, 8, 0),
+			daily: [2]string{"0900", "1700"},
+			want:  []Window{{at(5, 9, 0), at(5, 17, 0)}},
+		},
+		{
+			name:  "no overlap",
+			start: at(4, 18, 0), end: at(4, 23, 0),
+			daily: [2]string{"09:00", "17:00"},
+		},
+		{
+			name:  "bad clock",
+			start: at(4, 9, 0), end: at(4, 17, 0),
+			daily: [2]string{"9", "17:00"},
+			err:   ErrBadClock,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DailyWindows(tt.start, tt.end, tt.daily[0], tt.daily[1])
+			if err != tt.err {
+				t.Fatalf("DailyWindows error = %v, want %v", err, tt.err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("DailyWindows = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if !got[i].Start.Equal(tt.want[i].Start) || !got[i].End.Equal(tt.want[i].End) {
+					t.Errorf("window %d = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
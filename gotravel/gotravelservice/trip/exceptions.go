@@ -0,0 +1,138 @@
+package trip
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/holiday"
+)
+
+var ErrBadException = errors.New("exception must have date in 'YYYY-MM-DD' format and opening hours in 'hh:mm' " +
+	"format, exception of request must have region")
+
+const dateLayout = "2006-01-02"
+
+// Exception overrides weekly opening hours of a place on a date, place is
+// closed that day if Hours are empty.
+type Exception struct {
+	Date  string         `json:"date"`
+	Name  string         `json:"name,omitempty"`
+	Hours []OpeningHours `json:"hours,omitempty"`
+}
+
+// RegionException applies to every place with address in Region, like a
+// country or a city.
+type RegionException struct {
+	Region string `json:"region"`
+	Exception
+}
+
+// Normalize validates exception and converts its hours to 'hhmm' format.
+func (e *Exception) Normalize() error {
+	if _, err := time.Parse(dateLayout, e.Date); err != nil {
+		return ErrBadException
+	}
+	for i, oh := range e.Hours {
+		o, c := strings.ReplaceAll(oh.Open, ":", ""), strings.ReplaceAll(oh.Close, ":", "")
+		if !isHHMM(o) || !isHHMM(c) {
+			return ErrBadException
+		}
+		e.Hours[i] = OpeningHours{Open: o, Close: c}
+		if c <= o {
+			e.Hours[i].CloseDay = 1
+		}
+	}
+	return nil
+}
+
+// Normalize validates exception has region and normalizes it as Exception.
+func (re *RegionException) Normalize() error {
+	if strings.TrimSpace(re.Region) == "" {
+		return ErrBadException
+	}
+	return re.Exception.Normalize()
+}
+
+// LoadExceptions reads JSON file with list of region exceptions.
+func LoadExceptions(path string) ([]RegionException, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var exceptions []RegionException
+	if err = json.Unmarshal(data, &exceptions); err != nil {
+		return nil, fmt.Errorf("bad exceptions %s: %v", path, err)
+	}
+	for i := range exceptions {
+		if exceptions[i].Region == "" {
+			return nil, fmt.Errorf("bad exceptions %s: every exception must have region", path)
+		}
+		if err = exceptions[i].Normalize(); err != nil {
+			return nil, fmt.Errorf("bad exceptions %s: %v", path, err)
+		}
+	}
+	return exceptions, nil
+}
+
+// SetExceptions sets exceptions of place from regions its address is in,
+// then its own ones, later exceptions for the same date override earlier.
+func (p *Place) SetExceptions(regions []RegionException, own []Exception) {
+	for _, re := range regions {
+		if InRegion(p.Details.FormattedAddress, re.Region) {
+			p.Details.setException(re.Exception)
+		}
+	}
+	for _, e := range own {
+		p.Details.setException(e)
+	}
+}
+
+func (d *PlaceDetails) setException(e Exception) {
+	if d.Exceptions == nil {
+		d.Exceptions = make(map[string][]OpeningHours)
+	}
+	hours := make([]OpeningHours, len(e.Hours))
+	copy(hours, e.Hours)
+	sortHours(hours)
+	d.Exceptions[e.Date] = hours
+}
+
+// SetHolidays makes place open as on Sunday on public holidays of the
+// country its address is in, if there is a calendar of it.
+func (p *Place) SetHolidays() {
+	p.Details.Holidays, _ = holiday.ForAddress(p.Details.FormattedAddress)
+}
+
+// PeriodsOn returns opening periods of the place on day, given in place's
+// time zone, exceptions override weekly opening hours and public holidays.
+func (d *PlaceDetails) PeriodsOn(day time.Time) []OpeningHours {
+	if hours, ok := d.Exceptions[day.Format(dateLayout)]; ok {
+		return hours
+	}
+	if d.Holidays != nil {
+		if _, ok := d.Holidays.Holiday(day); ok {
+			return d.OpeningHoursPeriods[time.Sunday]
+		}
+	}
+	return d.OpeningHoursPeriods[day.Weekday()]
+}
+
+// InRegion checks whether one of comma separated parts of address is the
+// region, or starts or ends with it, like "50-153 Wrocław" for "Wrocław".
+func InRegion(address, region string) bool {
+	region = strings.ToLower(strings.TrimSpace(region))
+	if region == "" {
+		return false
+	}
+	for _, part := range strings.Split(strings.ToLower(address), ",") {
+		part = strings.TrimSpace(part)
+		if part == region || strings.HasPrefix(part, region+" ") || strings.HasSuffix(part, " "+region) {
+			return true
+		}
+	}
+	return false
+}
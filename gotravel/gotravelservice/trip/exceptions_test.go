@@ -0,0 +1,120 @@
+package trip
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestInRegion(t *testing.T) {
+	const address = "Hydropolis, Na Grobli 17, 50-421 Wrocław, Poland"
+	tests := []struct {
+		region string
+		want   bool
+	}{
+		{"Poland", true},
+		{"poland", true},
+		{" Wrocław ", true},
+		{"50-421", true},
+		{"Na Grobli 17", true},
+		{"Na Grobli", true},
+		{"Wroc", false},
+		{"Grobli", false},
+		{"land", false},
+		{"Kraków", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := InRegion(address, tt.region); got != tt.want {
+			t.Errorf("InRegion(%q) = %v, want %v", tt.region, got, tt.want)
+		}
+	}
+}
+
+// weekdays returns opening hours of every day between open and close, except
+// Sunday when place is closed.
+func weekdays(open, close string) map[time.Weekday][]OpeningHours {
+	hours := make(map[time.Weekday][]OpeningHours, 7)
+	hours[time.Sunday] = []OpeningHours{}
+	for d := time.Monday; d <= time.Saturday; d++ {
+		hours[d] = []OpeningHours{{Open: open, Close: close}}
+	}
+	return hours
+}
+
+func TestPeriodsOn(t *testing.T) {
+	p := &Place{Details: PlaceDetails{
+		FormattedAddress:    "Na Grobli 17, 50-421 Wrocław, Poland",
+		OpeningHoursPeriods: weekdays("1000", "1800"),
+	}}
+	p.SetExceptions(
+		[]RegionException{
+			{Region: "Poland", Exception: Exception{Date: "2024-06-05", Hours: []OpeningHours{{Open: "1200", Close: "1600"}}}},
+			{Region: "Poland", Exception: Exception{Date: "2024-06-06", Hours: []OpeningHours{{Open: "1200", Close: "1600"}}}},
+			{Region: "Kraków", Exception: Exception{Date: "2024-06-07"}},
+		},
+		[]Exception{
+			// own exception overrides the one of region
+			{Date: "2024-06-06", Hours: []OpeningHours{{Open: "1400", Close: "1500"}, {Open: "0900", Close: "1100"}}},
+			{Date: "2024-06-08", Name: "Closed for renovation"},
+		},
+	)
+
+	day := func(d int) time.Time {
+		return time.Date(2024, time.June, d, 0, 0, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name string
+		day  time.Time
+		want []OpeningHours
+	}{
+		{"weekday", day(4), []OpeningHours{{Open: "1000", Close: "1800"}}},
+		{"region exception", day(5), []OpeningHours{{Open: "1200", Close: "1600"}}},
+		{"own exception", day(6), []OpeningHours{{Open: "0900", Close: "1100"}, {Open: "1400", Close: "1500"}}},
+		{"exception of other region", day(7), []OpeningHours{{Open: "1000", Close: "1800"}}},
+		{"closed by exception", day(8), []OpeningHours{}},
+		{"closed on weekday", day(9), []OpeningHours{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.Details.PeriodsOn(tt.day); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PeriodsOn(%s) = %v, want %v", tt.day.Format(dateLayout), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPeriodsOnHoliday(t *testing.T) {
+	p := &Place{Details: PlaceDetails{
+		FormattedAddress:    "Na Grobli 17, 50-421 Wrocław, Poland",
+		OpeningHoursPeriods: weekdays("1000", "1800"),
+	}}
+	p.Details.OpeningHoursPeriods[time.Sunday] = []OpeningHours{{Open: "1100", Close: "1500"}}
+	p.SetHolidays()
+	p.SetExceptions(nil, []Exception{{Date: "2024-05-01", Hours: []OpeningHours{{Open: "1200", Close: "1300"}}}})
+
+	day := func(m time.Month, d int) time.Time {
+		return time.Date(2024, m, d, 0, 0, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name string
+		day  time.Time
+		want []OpeningHours
+	}{
+		{"weekday", day(time.May, 29), []OpeningHours{{Open: "1000", Close: "1800"}}},
+		{"holiday", day(time.May, 30), []OpeningHours{{Open: "1100", Close: "1500"}}},
+		{"exception on holiday", day(time.May, 1), []OpeningHours{{Open: "1200", Close: "1300"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.Details.PeriodsOn(tt.day); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PeriodsOn(%s) = %v, want %v", tt.day.Format(dateLayout), got, tt.want)
+			}
+		})
+	}
+
+	p.Details.Holidays = nil
+	if got, want := p.Details.PeriodsOn(day(time.May, 30)), p.Details.OpeningHoursPeriods[time.Thursday]; !reflect.DeepEqual(got, want) {
+		t.Errorf("PeriodsOn holiday without calendar = %v, want weekday hours %v", got, want)
+	}
+}
@@ -4,22 +4,82 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/holiday"
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/provider"
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/usage"
 	"github.com/radekwlsk/go-travel/utils"
-	"googlemaps.github.io/maps"
 )
 
-var ErrZeroResults = errors.New("google maps API query returned no result")
+var ErrZeroResults = provider.ErrZeroResults
+
+var ErrBadTolerance = errors.New("appointment tolerance can't be negative")
 
 type PlaceConfig struct {
 	Priority     int         `json:"priority,omitempty"`
 	StayDuration int         `json:"stayDuration,omitempty"`
+	Mode         string      `json:"mode,omitempty"`
 	Description  interface{} `json:"description"`
 	Start        bool        `json:"start,omitempty"`
 	End          bool        `json:"end,omitempty"`
+	// Lodging is left every morning and returned to every evening.
+	Lodging bool `json:"lodging,omitempty"`
+	// Earliest and Latest limit visit to given times of day, in 'hh:mm'
+	// format, in addition to opening hours.
+	Earliest string `json:"earliest,omitempty"`
+	Latest   string `json:"latest,omitempty"`
+	// Required place is never left out, planning fails if it can't be
+	// visited.
+	Required bool `json:"required,omitempty"`
+	// Appointment pins arrival at the place to exact time.
+	Appointment *AppointmentConfig `json:"appointment,omitempty"`
+	// Exceptions override opening hours of the place on given dates.
+	Exceptions []Exception `json:"exceptions,omitempty"`
+}
+
+// AppointmentConfig is time of booked visit, in RFC3339 format, with minutes
+// the visit can start before or after it.
+type AppointmentConfig struct {
+	At    string `json:"at"`
+	Early int    `json:"early,omitempty"`
+	Late  int    `json:"late,omitempty"`
+}
+
+// Window returns time range the visit has to start in.
+func (ac *AppointmentConfig) Window() (Window, error) {
+	at, err := time.Parse(time.RFC3339, ac.At)
+	if err != nil {
+		return Window{}, err
+	}
+	if ac.Early < 0 || ac.Late < 0 {
+		return Window{}, ErrBadTolerance
+	}
+	return Window{
+		Start: at.Add(-time.Duration(ac.Early) * time.Minute),
+		End:   at.Add(time.Duration(ac.Late) * time.Minute),
+	}, nil
+}
+
+// VisitWindow returns earliest and latest visit times of the place in 'hhmm'
+// format, empty if not limited.
+func (pc *PlaceConfig) VisitWindow() (earliest, latest string, err error) {
+	for _, c := range []struct {
+		s   string
+		out *string
+	}{{pc.Earliest, &earliest}, {pc.Latest, &latest}} {
+		if c.s == "" {
+			continue
+		}
+		*c.out = strings.ReplaceAll(c.s, ":", "")
+		if !isHHMM(*c.out) {
+			return "", "", ErrBadClock
+		}
+	}
+	return earliest, latest, nil
 }
 
 var TravelModeOptions = []string{
@@ -33,22 +93,39 @@ var ModeOptions = []string{
 	"name",
 	"address",
 	"id",
+	"latlng",
+	"custom",
 }
 
 type Trip struct {
-	Places        []*Place  `json:"places"`
-	StartPlace    *Place    `json:"-"`
-	EndPlace      *Place    `json:"-"`
-	TripStart     time.Time `json:"tripStart"`
-	TripEnd       time.Time `json:"tripEnd"`
-	TotalDistance int64     `json:"totalDistance"`
-	Steps         []Step    `json:"steps"`
-	Schedule      string    `json:"schedule"`
-	Path          []int     `json:"path"`
-	TravelMode    maps.Mode `json:"travelMode"`
+	Places        []*Place            `json:"places"`
+	StartPlace    *Place              `json:"-"`
+	EndPlace      *Place              `json:"-"`
+	Lodging       *Place              `json:"-"`
+	TripStart     time.Time           `json:"tripStart"`
+	TripEnd       time.Time           `json:"tripEnd"`
+	TotalDistance int64               `json:"totalDistance"`
+	Steps         []Step              `json:"steps"`
+	Schedule      string              `json:"schedule"`
+	Path          []int               `json:"path"`
+	TravelMode    provider.TravelMode `json:"travelMode"`
+	// Windows are daily active windows of multi-day trip, nil if the trip
+	// is planned as one continuous window.
+	Windows []Window `json:"-"`
+	// Breaks are taken every day of the trip.
+	Breaks []Break `json:"-"`
+	// Days are parts of multi-day trip visited in every window.
+	Days []Day `json:"days,omitempty"`
+	// Usage counts provider calls made to plan the trip.
+	Usage usage.Usage `json:"usage"`
 }
 
 func (t *Trip) CreateSchedule() {
+	if t.Windows != nil {
+		t.createDaysSchedule()
+		return
+	}
+
 	var dStrings = make([]string, len(t.Places))
 	var aStrings = make([]string, len(t.Places))
 
@@ -61,26 +138,36 @@ func (t *Trip) CreateSchedule() {
 		}
 	}
 
-	var sStrings = make([]string, len(t.Steps))
+	var sStrings []string
 
-	for i, s := range t.Steps {
-		sStrings[i] = fmt.Sprintf(
+	// place of a break is listed once, before the break
+	var listed = -1
+	var visit = func(i int) {
+		if i == listed {
+			return
+		}
+		listed = i
+		sStrings = append(sStrings, fmt.Sprintf(
 			"[%s - %s] %s, %s",
-			aStrings[s.From],
-			dStrings[s.From],
-			t.Places[s.From].Details.Name,
-			t.Places[s.From].Details.FormattedAddress)
+			aStrings[i],
+			dStrings[i],
+			t.Places[i].Details.Name,
+			t.Places[i].Details.FormattedAddress))
 	}
 
-	if t.StartPlace != nil && t.EndPlace != t.StartPlace {
-		last := t.Steps[len(t.Steps)-1].To
-		sStrings = append(sStrings, fmt.Sprintf(
-			"[%s - %s] %s, %s",
-			aStrings[last],
-			dStrings[last],
-			t.Places[last].Details.Name,
-			t.Places[last].Details.FormattedAddress))
+	for _, s := range t.Steps {
+		visit(s.From)
+		if s.Break != nil {
+			sStrings = append(sStrings, fmt.Sprintf(
+				"[%s - %s] %s",
+				s.Break.Start.Format("Mon Jan 2, 15:04"),
+				s.Break.End.Format("15:04"),
+				s.Break.Name))
+		}
+	}
 
+	if t.StartPlace != nil && t.EndPlace != t.StartPlace {
+		visit(t.Steps[len(t.Steps)-1].To)
 	} else if t.EndPlace != nil {
 		sStrings = append(sStrings, fmt.Sprintf(
 			"[%s] %s, %s",
@@ -98,20 +185,50 @@ type Configuration struct {
 	TripStart           string         `json:"tripStart"`
 	TripEnd             string         `json:"tripEnd"`
 	TravelMode          string         `json:"travelMode,omitempty"`
+	DailyStart          string         `json:"dailyStart,omitempty"`
+	DailyEnd            string         `json:"dailyEnd,omitempty"`
 	PlacesConfiguration []*PlaceConfig `json:"places"`
+	Precedence          []Precedence   `json:"precedence,omitempty"`
+	Breaks              []Break        `json:"breaks,omitempty"`
+	// Exceptions override opening hours of places in regions on given
+	// dates, in addition to exceptions configured on the server.
+	Exceptions []RegionException `json:"exceptions,omitempty"`
+	// HolidaySundayHours applies Sunday opening hours of places on public
+	// holidays of countries they are in.
+	HolidaySundayHours bool `json:"holidaySundayHours,omitempty"`
+}
+
+// Precedence requires place with index Before to be visited before place
+// with index After, the latter is left out if the former is not visited.
+type Precedence struct {
+	Before int `json:"before"`
+	After  int `json:"after"`
 }
 
 type PlaceDetails struct {
-	PermanentlyClosed   bool                          `json:"closed"`
-	OpeningHoursPeriods map[time.Weekday]OpeningHours `json:"openingHours"`
-	Location            *time.Location                `json:"-"`
-	FormattedAddress    string                        `json:"formattedAddress"`
-	Name                string                        `json:"name"`
+	PermanentlyClosed bool `json:"closed"`
+	// OpeningHoursPeriods are opening periods of every weekday sorted by
+	// opening time, place is closed on days without any.
+	OpeningHoursPeriods map[time.Weekday][]OpeningHours `json:"openingHours"`
+	Location            *time.Location                  `json:"-"`
+	FormattedAddress    string                          `json:"formattedAddress"`
+	Name                string                          `json:"name"`
+	Coordinates         provider.LatLng                 `json:"coordinates"`
+	Tags                map[string]string               `json:"tags,omitempty"`
+	// Exceptions are opening periods on dates in 'YYYY-MM-DD' format that
+	// override OpeningHoursPeriods, place is closed on dates without any.
+	Exceptions map[string][]OpeningHours `json:"exceptions,omitempty"`
+	// Holidays is calendar of public holidays on which the place is open
+	// as on Sunday, nil if holidays are not considered.
+	Holidays *holiday.Calendar `json:"-"`
 }
 
 type OpeningHours struct {
 	Open  string `json:"open"`
 	Close string `json:"close"`
+	// CloseDay is number of days after opening the place closes, 1 for
+	// places closing after midnight.
+	CloseDay int `json:"closeDay,omitempty"`
 }
 
 type Place struct {
@@ -122,50 +239,59 @@ type Place struct {
 	Arrival      time.Time    `json:"arrival,omitempty"`
 	Departure    time.Time    `json:"departure,omitempty"`
 	Details      PlaceDetails `json:"details,omitempty"`
+	// Earliest and Latest are times of day in 'hhmm' format the visit can't
+	// start before and end after.
+	Earliest string `json:"earliest,omitempty"`
+	Latest   string `json:"latest,omitempty"`
+	// Required place has to be visited.
+	Required bool `json:"required,omitempty"`
+	// Appointment is time range the visit has to start in, if booked.
+	Appointment *Window `json:"appointment,omitempty"`
+	// Predecessors are indexes of places that have to be visited first.
+	Predecessors []int `json:"predecessors,omitempty"`
+	// Pinned places are passed to matrix requests by their exact coordinates
+	// instead of address.
+	Pinned bool `json:"-"`
 }
 
-func (p *Place) SetDetails(service interface{}, c *maps.Client, lang string) error {
-	r := &maps.PlaceDetailsRequest{
-		PlaceID:  p.PlaceID,
-		Language: lang,
-	}
-	var resp maps.PlaceDetailsResult
-	resp, err := c.PlaceDetails(context.Background(), r)
+func (p *Place) SetDetails(ctx context.Context, f provider.DetailsFetcher, lang string) error {
+	resp, err := f.PlaceDetails(ctx, p.PlaceID, lang)
 	if err != nil {
 		return err
 	}
 
 	var location *time.Location
-	{
+	if resp.UTCOffset != nil {
 		offset := *resp.UTCOffset * 60
 		name := strconv.Itoa(*resp.UTCOffset / 60)
 		location = time.FixedZone(name, offset)
 	}
-	var openingHours = make(map[time.Weekday]OpeningHours, 7)
+	var openingHours = make(map[time.Weekday][]OpeningHours, 7)
 
-	if resp.OpeningHours != nil {
+	if resp.Periods != nil {
 		for i := 0; i < 7; i++ {
-			openingHours[time.Weekday(i)] = OpeningHours{}
+			openingHours[time.Weekday(i)] = []OpeningHours{}
 		}
 
-		for _, o := range resp.OpeningHours.Periods {
+		for _, o := range resp.Periods {
 			if o.Open.Time == "" && o.Close.Time == "" {
 				continue
 			} else if o.Open.Time == "0000" && o.Close.Time == "" {
-				for i := range openingHours {
-					openingHours[i] = OpeningHours{
-						Open:  "0000",
-						Close: "2359",
-					}
-				}
+				openingHours = alwaysOpen()
 				break
 			} else {
-				openingHours[o.Open.Day] = OpeningHours{
-					Open:  o.Open.Time,
-					Close: o.Close.Time,
+				closeDay := int(o.Close.Day-o.Open.Day+7) % 7
+				if closeDay == 0 && o.Close.Time <= o.Open.Time {
+					closeDay = 7
 				}
+				openingHours[o.Open.Day] = append(openingHours[o.Open.Day], OpeningHours{
+					Open:     o.Open.Time,
+					Close:    o.Close.Time,
+					CloseDay: closeDay,
+				})
 			}
 		}
+		sortPeriods(openingHours)
 	}
 
 	p.Details = PlaceDetails{
@@ -174,15 +300,55 @@ func (p *Place) SetDetails(service interface{}, c *maps.Client, lang string) err
 		Location:            location,
 		FormattedAddress:    resp.FormattedAddress,
 		Name:                resp.Name,
+		Coordinates:         resp.LatLng,
+		Tags:                resp.Tags,
 	}
 	return nil
 }
 
+// Waypoint returns place as origin or destination of provider matrix request.
+func (p *Place) Waypoint() provider.Waypoint {
+	coordinates := p.Details.Coordinates
+	if p.Pinned {
+		return provider.Waypoint{LatLng: &coordinates}
+	}
+	return provider.Waypoint{
+		Address: p.Details.FormattedAddress,
+		LatLng:  &coordinates,
+	}
+}
+
+func alwaysOpen() map[time.Weekday][]OpeningHours {
+	openingHours := make(map[time.Weekday][]OpeningHours, 7)
+	for i := 0; i < 7; i++ {
+		openingHours[time.Weekday(i)] = []OpeningHours{{
+			Open:     "0000",
+			Close:    "0000",
+			CloseDay: 1,
+		}}
+	}
+	return openingHours
+}
+
+func sortPeriods(openingHours map[time.Weekday][]OpeningHours) {
+	for _, periods := range openingHours {
+		sortHours(periods)
+	}
+}
+
+func sortHours(periods []OpeningHours) {
+	sort.Slice(periods, func(i, j int) bool {
+		return periods[i].Open < periods[j].Open
+	})
+}
+
 type Step struct {
 	From     int           `json:"from"`
 	To       int           `json:"to"`
 	Duration time.Duration `json:"time"`
 	Distance int64         `json:"distance"`
+	// Break is set for steps of taking break at place From, equal to To.
+	Break *TakenBreak `json:"break,omitempty"`
 }
 
 type Path struct {
@@ -244,11 +410,20 @@ func (p *Path) Path() []int {
 	return p.path
 }
 
+// Description identifies place in a mode specific way and resolves it to
+// place ID of the provider.
 type Description interface {
-	MapsPlaceID(interface{}, *maps.Client) (string, error)
+	Resolve(context.Context, provider.PlaceResolver) (string, error)
 	String() string
 }
 
+// Describer is implemented by descriptions that set place details
+// themselves. Describe is called after place details were fetched, or
+// instead of that if Resolve returned empty place ID.
+type Describer interface {
+	Describe(*Place)
+}
+
 type AddressDescription struct {
 	Name       string `json:"name,omitempty"`
 	Street     string `json:"street"`
@@ -283,50 +458,16 @@ func (ad *AddressDescription) String() (address string) {
 	return
 }
 
-func (ad *AddressDescription) MapsPlaceID(service interface{}, c *maps.Client) (string, error) {
-	var placeId string
-	{
-		r := &maps.PlaceAutocompleteRequest{
-			Input: ad.String(),
-			Types: maps.AutocompletePlaceTypeEstablishment,
-		}
-		var resp maps.AutocompleteResponse
-		resp, err := c.PlaceAutocomplete(context.Background(), r)
-		if err != nil {
-			if strings.Contains(err.Error(), "ZERO_RESULTS") {
-				return "", ErrZeroResults
-			}
-			return "", err
-		}
-		placeId = resp.Predictions[0].PlaceID
-	}
-
-	return placeId, nil
+func (ad *AddressDescription) Resolve(ctx context.Context, r provider.PlaceResolver) (string, error) {
+	return r.PlaceID(ctx, ad.String())
 }
 
 type NameDescription struct {
 	Name string `json:"name"`
 }
 
-func (nd *NameDescription) MapsPlaceID(service interface{}, c *maps.Client) (string, error) {
-	var placeId string
-	{
-		r := &maps.PlaceAutocompleteRequest{
-			Input: nd.Name,
-			Types: maps.AutocompletePlaceTypeEstablishment,
-		}
-		var resp maps.AutocompleteResponse
-		resp, err := c.PlaceAutocomplete(context.Background(), r)
-		if err != nil {
-			if strings.Contains(err.Error(), "ZERO_RESULTS") {
-				return "", ErrZeroResults
-			}
-			return "", err
-		}
-		placeId = resp.Predictions[0].PlaceID
-	}
-
-	return placeId, nil
+func (nd *NameDescription) Resolve(ctx context.Context, r provider.PlaceResolver) (string, error) {
+	return r.PlaceID(ctx, nd.Name)
 }
 
 func (nd *NameDescription) String() string {
@@ -337,10 +478,187 @@ type PlaceIDDescription struct {
 	PlaceID string `json:"placeId"`
 }
 
-func (pid *PlaceIDDescription) MapsPlaceID(service interface{}, c *maps.Client) (string, error) {
+func (pid *PlaceIDDescription) Resolve(context.Context, provider.PlaceResolver) (string, error) {
 	return pid.PlaceID, nil
 }
 
 func (pid *PlaceIDDescription) String() string {
 	return pid.PlaceID
 }
+
+type LatLngDescription struct {
+	Lat    *float64 `json:"lat"`
+	Lng    *float64 `json:"lng"`
+	Label  string   `json:"label,omitempty"`
+	Lookup bool     `json:"lookup,omitempty"`
+}
+
+func (lld *LatLngDescription) IsValid() bool {
+	return lld.Lat != nil && lld.Lng != nil &&
+		*lld.Lat >= -90 && *lld.Lat <= 90 &&
+		*lld.Lng >= -180 && *lld.Lng <= 180
+}
+
+func (lld *LatLngDescription) LatLng() provider.LatLng {
+	return provider.LatLng{Lat: *lld.Lat, Lng: *lld.Lng}
+}
+
+// Resolve finds place at the coordinates only if lookup was requested,
+// otherwise place details are not fetched at all.
+func (lld *LatLngDescription) Resolve(ctx context.Context, r provider.PlaceResolver) (string, error) {
+	if !lld.Lookup {
+		return "", nil
+	}
+	return r.PlaceIDAt(ctx, lld.LatLng())
+}
+
+// Describe pins place to exact coordinates. Place that was not looked up is
+// named by label and considered to be always open.
+func (lld *LatLngDescription) Describe(p *Place) {
+	p.Pinned = true
+	p.Details.Coordinates = lld.LatLng()
+	if lld.Label != "" {
+		p.Details.Name = lld.Label
+	}
+	if p.PlaceID == "" {
+		if p.Details.Name == "" {
+			p.Details.Name = lld.String()
+		}
+		p.Details.FormattedAddress = lld.LatLng().String()
+		p.Details.OpeningHoursPeriods = alwaysOpen()
+	}
+}
+
+func (lld *LatLngDescription) String() string {
+	if lld.Label != "" {
+		return lld.Label
+	}
+	if lld.Lat == nil || lld.Lng == nil {
+		return ""
+	}
+	return lld.LatLng().String()
+}
+
+// CustomDescription describes place that is not looked up at all, with all
+// details provided in the request. Opening hours are keyed by weekday number
+// (0 is Sunday) or English weekday name, with one or more opening periods
+// per day, place is considered to be always open if they are not provided.
+type CustomDescription struct {
+	Name         string                    `json:"name"`
+	Lat          *float64                  `json:"lat"`
+	Lng          *float64                  `json:"lng"`
+	Address      string                    `json:"address,omitempty"`
+	OpeningHours map[string][]OpeningHours `json:"openingHours,omitempty"`
+	TimeZone     string                    `json:"timeZone,omitempty"`
+}
+
+func (cd *CustomDescription) IsValid() bool {
+	if cd.Name == "" {
+		return false
+	}
+	ll := LatLngDescription{Lat: cd.Lat, Lng: cd.Lng}
+	if !ll.IsValid() {
+		return false
+	}
+	if _, err := cd.openingHours(); err != nil {
+		return false
+	}
+	if _, err := cd.location(); err != nil {
+		return false
+	}
+	return true
+}
+
+func (cd *CustomDescription) Resolve(context.Context, provider.PlaceResolver) (string, error) {
+	return "", nil
+}
+
+func (cd *CustomDescription) Describe(p *Place) {
+	ll := provider.LatLng{Lat: *cd.Lat, Lng: *cd.Lng}
+	p.Pinned = true
+	p.Details = PlaceDetails{
+		Name:             cd.Name,
+		FormattedAddress: cd.Address,
+		Coordinates:      ll,
+	}
+	if p.Details.FormattedAddress == "" {
+		p.Details.FormattedAddress = ll.String()
+	}
+	p.Details.OpeningHoursPeriods, _ = cd.openingHours()
+	p.Details.Location, _ = cd.location()
+}
+
+func (cd *CustomDescription) String() string {
+	return cd.Name
+}
+
+func (cd *CustomDescription) openingHours() (map[time.Weekday][]OpeningHours, error) {
+	if len(cd.OpeningHours) == 0 {
+		return alwaysOpen(), nil
+	}
+	openingHours := make(map[time.Weekday][]OpeningHours, 7)
+	for i := 0; i < 7; i++ {
+		openingHours[time.Weekday(i)] = []OpeningHours{}
+	}
+	for day, periods := range cd.OpeningHours {
+		wd, err := parseWeekday(day)
+		if err != nil {
+			return nil, err
+		}
+		for _, oh := range periods {
+			o, c := strings.ReplaceAll(oh.Open, ":", ""), strings.ReplaceAll(oh.Close, ":", "")
+			if !isHHMM(o) || !isHHMM(c) {
+				return nil, fmt.Errorf("opening hours of %s not in 'hhmm' format", day)
+			}
+			oh := OpeningHours{Open: o, Close: c}
+			if c <= o {
+				oh.CloseDay = 1
+			}
+			openingHours[wd] = append(openingHours[wd], oh)
+		}
+	}
+	sortPeriods(openingHours)
+	return openingHours, nil
+}
+
+// location returns nil if time zone is not provided, it is then set to the
+// time zone of trip start.
+func (cd *CustomDescription) location() (*time.Location, error) {
+	if cd.TimeZone == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse("-07:00", cd.TimeZone); err == nil {
+		_, offset := t.Zone()
+		return time.FixedZone(cd.TimeZone, offset), nil
+	}
+	return time.LoadLocation(cd.TimeZone)
+}
+
+func parseWeekday(s string) (time.Weekday, error) {
+	if i, err := strconv.Atoi(s); err == nil && i >= 0 && i < 7 {
+		return time.Weekday(i), nil
+	}
+	for i := time.Sunday; i <= time.Saturday; i++ {
+		name := i.String()
+		if strings.EqualFold(s, name) || strings.EqualFold(s, name[:3]) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("%q is not a weekday", s)
+}
+
+// isHHMM reports whether s is time of day of exactly four digits, hours
+// 00-23 and minutes 00-59.
+func isHHMM(s string) bool {
+	if len(s) != 4 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	hh, _ := strconv.Atoi(s[:2])
+	mm, _ := strconv.Atoi(s[2:])
+	return hh <= 23 && mm <= 59
+}
@@ -0,0 +1,30 @@
+package trip
+
+import "testing"
+
+func TestVisitWindow(t *testing.T) {
+	tests := []struct {
+		earliest, latest string
+		want             [2]string
+		err              error
+	}{
+		{"09:30", "17:00", [2]string{"0930", "1700"}, nil},
+		{"0000", "2359", [2]string{"0000", "2359"}, nil},
+		{"", "12:00", [2]string{"", "1200"}, nil},
+		{"", "", [2]string{}, nil},
+		{"9:30", "", [2]string{}, ErrBadClock},
+		{"-130", "", [2]string{}, ErrBadClock},
+		{"+930", "", [2]string{}, ErrBadClock},
+		{"", "-1:30", [2]string{}, ErrBadClock},
+		{"09 30", "", [2]string{}, ErrBadClock},
+		{"24:00", "", [2]string{}, ErrBadClock},
+		{"", "12:60", [2]string{}, ErrBadClock},
+	}
+	for _, tt := range tests {
+		pc := &PlaceConfig{Earliest: tt.earliest, Latest: tt.latest}
+		e, l, err := pc.VisitWindow()
+		if err != tt.err || [2]string{e, l} != tt.want {
+			t.Errorf("VisitWindow(%q, %q) = %q, %q, %v, want %q, %v", tt.earliest, tt.latest, e, l, err, tt.want, tt.err)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package usage
+
+import (
+	"context"
+
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/provider"
+)
+
+// Provider counts calls to the next provider as billable in Counter of the
+// call context. Only calls that got a response are counted.
+type Provider struct {
+	next provider.Provider
+}
+
+func NewProvider(next provider.Provider) *Provider {
+	return &Provider{next: next}
+}
+
+// NewFactory returns provider.Factory wrapping providers created by next
+// factory with Provider, it should wrap factory of the billed provider only.
+func NewFactory(next provider.Factory) provider.Factory {
+	return func(apiKey string) (provider.Provider, error) {
+		p, err := next(apiKey)
+		if err != nil {
+			return nil, err
+		}
+		return NewProvider(p), nil
+	}
+}
+
+func (p *Provider) PlaceID(ctx context.Context, query string) (string, error) {
+	id, err := p.next.PlaceID(ctx, query)
+	count(ctx, Autocomplete, 1, err)
+	return id, err
+}
+
+func (p *Provider) PlaceIDAt(ctx context.Context, ll provider.LatLng) (string, error) {
+	id, err := p.next.PlaceIDAt(ctx, ll)
+	count(ctx, Geocoding, 1, err)
+	return id, err
+}
+
+func (p *Provider) PlaceDetails(ctx context.Context, placeID string, language string) (provider.Details, error) {
+	d, err := p.next.PlaceDetails(ctx, placeID, language)
+	count(ctx, Details, 1, err)
+	return d, err
+}
+
+func (p *Provider) DistanceMatrix(ctx context.Context, r provider.MatrixRequest) (provider.Matrix, error) {
+	m, err := p.next.DistanceMatrix(ctx, r)
+	count(ctx, Matrix, 1, err)
+	count(ctx, MatrixElements, len(r.Origins)*len(r.Destinations), err)
+	return m, err
+}
+
+func (p *Provider) MatrixLimits(mode provider.TravelMode) provider.MatrixLimits {
+	return p.next.MatrixLimits(mode)
+}
+
+func count(ctx context.Context, k Kind, n int, err error) {
+	if err == nil || err == provider.ErrZeroResults {
+		FromContext(ctx).Billable(k, n)
+	}
+}
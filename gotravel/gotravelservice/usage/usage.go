@@ -0,0 +1,155 @@
+// Package usage counts provider calls and travel matrix elements used to plan
+// trips, so that their cost can be reported and charged back per tenant.
+package usage
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Kind of counted provider calls.
+type Kind int
+
+const (
+	Autocomplete Kind = iota
+	Geocoding
+	Details
+	Matrix
+	MatrixElements
+)
+
+// Count splits calls to ones billed by the provider and ones served from
+// cache.
+type Count struct {
+	Billable int64 `json:"billable"`
+	Cached   int64 `json:"cached"`
+}
+
+func (c *Count) add(o Count) {
+	c.Billable += o.Billable
+	c.Cached += o.Cached
+}
+
+// Usage counts provider calls of every kind, matrix requests and their
+// elements are counted separately.
+type Usage struct {
+	Autocomplete   Count `json:"autocomplete"`
+	Geocoding      Count `json:"geocoding"`
+	Details        Count `json:"details"`
+	Matrix         Count `json:"matrix"`
+	MatrixElements Count `json:"matrixElements"`
+}
+
+func (u *Usage) count(k Kind) *Count {
+	switch k {
+	case Autocomplete:
+		return &u.Autocomplete
+	case Geocoding:
+		return &u.Geocoding
+	case Details:
+		return &u.Details
+	case Matrix:
+		return &u.Matrix
+	default:
+		return &u.MatrixElements
+	}
+}
+
+// Add adds counts of o to u.
+func (u *Usage) Add(o Usage) {
+	for k := Autocomplete; k <= MatrixElements; k++ {
+		u.count(k).add(*o.count(k))
+	}
+}
+
+// Counter counts usage of single request, it is safe for concurrent use.
+// Methods of nil Counter do nothing.
+type Counter struct {
+	mtx   sync.Mutex
+	usage Usage
+}
+
+func (c *Counter) Billable(k Kind, n int) {
+	if c == nil {
+		return
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.usage.count(k).Billable += int64(n)
+}
+
+func (c *Counter) Cached(k Kind, n int) {
+	if c == nil {
+		return
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.usage.count(k).Cached += int64(n)
+}
+
+// Usage returns counts so far.
+func (c *Counter) Usage() Usage {
+	if c == nil {
+		return Usage{}
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.usage
+}
+
+type contextKey struct{}
+
+// NewContext returns context carrying new Counter of its calls.
+func NewContext(ctx context.Context) (context.Context, *Counter) {
+	c := &Counter{}
+	return context.WithValue(ctx, contextKey{}, c), c
+}
+
+// FromContext returns Counter of the context or nil if it has none.
+func FromContext(ctx context.Context) *Counter {
+	c, _ := ctx.Value(contextKey{}).(*Counter)
+	return c
+}
+
+// Report is running total usage of every tenant.
+type Report struct {
+	Since   time.Time        `json:"since"`
+	Tenants map[string]Usage `json:"tenants"`
+}
+
+// Ledger keeps running totals of usage per tenant since it was created, it
+// is safe for concurrent use.
+type Ledger struct {
+	mtx     sync.Mutex
+	since   time.Time
+	tenants map[string]Usage
+}
+
+func NewLedger() *Ledger {
+	return &Ledger{since: time.Now(), tenants: make(map[string]Usage)}
+}
+
+func (l *Ledger) Add(tenant string, u Usage) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	total := l.tenants[tenant]
+	total.Add(u)
+	l.tenants[tenant] = total
+}
+
+// Report returns totals of given tenants or of all of them if none given.
+func (l *Ledger) Report(tenants ...string) Report {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	r := Report{Since: l.since, Tenants: make(map[string]Usage)}
+	if len(tenants) == 0 {
+		for t, u := range l.tenants {
+			r.Tenants[t] = u
+		}
+	}
+	for _, t := range tenants {
+		r.Tenants[t] = l.tenants[t]
+	}
+	return r
+}
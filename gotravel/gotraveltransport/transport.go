@@ -14,6 +14,7 @@ import (
 
 	"github.com/radekwlsk/go-travel/gotravel/gotravelendpoint"
 	"github.com/radekwlsk/go-travel/gotravel/gotravelservice"
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/tenant"
 
 	"github.com/go-kit/kit/log"
 	httptransport "github.com/go-kit/kit/transport/http"
@@ -24,6 +25,7 @@ func MakeHTTPHandler(endpoints gotravelendpoint.Endpoints, logger log.Logger) ht
 	options := []httptransport.ServerOption{
 		httptransport.ServerErrorLogger(logger),
 		httptransport.ServerErrorEncoder(errorEncoder),
+		httptransport.ServerBefore(tokenToContext),
 	}
 
 	m.Handle("/api/trip/", httptransport.NewServer(
@@ -33,6 +35,20 @@ func MakeHTTPHandler(endpoints gotravelendpoint.Endpoints, logger log.Logger) ht
 		options...,
 	))
 
+	m.Handle("/api/estimate/", httptransport.NewServer(
+		endpoints.EstimateEndpoint,
+		decodeEstimateRequest,
+		encodeResponse,
+		options...,
+	))
+
+	m.Handle("/api/usage/", httptransport.NewServer(
+		endpoints.UsageEndpoint,
+		decodeUsageRequest,
+		encodeResponse,
+		options...,
+	))
+
 	return m
 }
 
@@ -45,7 +61,9 @@ func MakeHTTPClient(instance string) (gotravelservice.Service, error) {
 		return nil, err
 	}
 
-	var options []httptransport.ClientOption
+	options := []httptransport.ClientOption{
+		httptransport.ClientBefore(tokenFromContext),
+	}
 
 	var tripPlanEndpoint endpoint.Endpoint
 	{
@@ -58,8 +76,32 @@ func MakeHTTPClient(instance string) (gotravelservice.Service, error) {
 		).Endpoint()
 	}
 
+	var estimateEndpoint endpoint.Endpoint
+	{
+		estimateEndpoint = httptransport.NewClient(
+			"POST",
+			copyURL(u, "/api/estimate/"),
+			encodeEstimateRequest,
+			decodeEstimateResponse,
+			options...,
+		).Endpoint()
+	}
+
+	var usageEndpoint endpoint.Endpoint
+	{
+		usageEndpoint = httptransport.NewClient(
+			"GET",
+			copyURL(u, "/api/usage/"),
+			httptransport.EncodeJSONRequest,
+			decodeUsageResponse,
+			options...,
+		).Endpoint()
+	}
+
 	return gotravelendpoint.Endpoints{
 		TripPlanEndpoint: tripPlanEndpoint,
+		EstimateEndpoint: estimateEndpoint,
+		UsageEndpoint:    usageEndpoint,
 	}, nil
 }
 
@@ -69,6 +111,25 @@ func copyURL(base *url.URL, path string) *url.URL {
 	return &next
 }
 
+const bearerPrefix = "Bearer "
+
+// tokenToContext puts bearer token of Authorization header into context.
+func tokenToContext(ctx context.Context, r *http.Request) context.Context {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, bearerPrefix) {
+		return ctx
+	}
+	return tenant.ContextWithToken(ctx, strings.TrimSpace(strings.TrimPrefix(auth, bearerPrefix)))
+}
+
+// tokenFromContext sets Authorization header to bearer token from context.
+func tokenFromContext(ctx context.Context, r *http.Request) context.Context {
+	if token := tenant.TokenFromContext(ctx); token != "" {
+		r.Header.Set("Authorization", bearerPrefix+token)
+	}
+	return ctx
+}
+
 func decodeTripPlanRequest(_ context.Context, r *http.Request) (interface{}, error) {
 	var request gotravelendpoint.TripPlanRequest
 	if err := json.NewDecoder(r.Body).Decode(&request.TripConfiguration); err != nil {
@@ -86,6 +147,40 @@ func decodeTripPlanResponse(_ context.Context, resp *http.Response) (interface{}
 	return response, err
 }
 
+func decodeEstimateRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var request gotravelendpoint.EstimateRequest
+	if err := json.NewDecoder(r.Body).Decode(&request.TripConfiguration); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+func decodeEstimateResponse(_ context.Context, resp *http.Response) (interface{}, error) {
+	if resp.StatusCode != http.StatusOK {
+		return nil, errorDecoder(resp)
+	}
+	var response gotravelendpoint.EstimateResponse
+	err := json.NewDecoder(resp.Body).Decode(&response)
+	return response, err
+}
+
+func encodeEstimateRequest(ctx context.Context, req *http.Request, request interface{}) error {
+	return encodeRequest(ctx, req, request.(gotravelendpoint.EstimateRequest).TripConfiguration)
+}
+
+func decodeUsageRequest(_ context.Context, _ *http.Request) (interface{}, error) {
+	return gotravelendpoint.UsageRequest{}, nil
+}
+
+func decodeUsageResponse(_ context.Context, resp *http.Response) (interface{}, error) {
+	if resp.StatusCode != http.StatusOK {
+		return nil, errorDecoder(resp)
+	}
+	var response gotravelendpoint.UsageResponse
+	err := json.NewDecoder(resp.Body).Decode(&response)
+	return response, err
+}
+
 func encodeTripPlanRequest(ctx context.Context, req *http.Request, request interface{}) error {
 	// r.Methods("POST").Path("/api/trip/")
 	req.Method, req.URL.Path = "POST", "/api/trip/"
@@ -128,13 +223,19 @@ func errorEncoder(_ context.Context, err error, w http.ResponseWriter) {
 	}
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	w.WriteHeader(errToStatus(err))
-	json.NewEncoder(w).Encode(map[string]interface{}{
+	response := map[string]interface{}{
 		"err": err.Error(),
-	})
+	}
+	if e, ok := err.(gotravelservice.ErrRequiredMissed); ok {
+		response["missed"] = e.Missed
+	}
+	json.NewEncoder(w).Encode(response)
 }
 
 func errToStatus(err error) int {
 	switch err {
+	case gotravelservice.ErrUnauthorized:
+		return http.StatusUnauthorized
 	case
 		gotravelservice.ErrAPIKeyEmpty,
 		gotravelservice.ErrModeEmpty,
@@ -146,6 +247,18 @@ func errToStatus(err error) int {
 		gotravelservice.ErrEndBeforeStart,
 		gotravelservice.ErrTwoStartPlaces,
 		gotravelservice.ErrTwoEndPlaces,
+		gotravelservice.ErrTwoLodgings,
+		gotravelservice.ErrLodgingStartEnd,
+		gotravelservice.ErrBadDailyWindow,
+		gotravelservice.ErrNoDailyWindow,
+		gotravelservice.ErrNoLodging,
+		gotravelservice.ErrBadVisitWindow,
+		gotravelservice.ErrBadAppointment,
+		gotravelservice.ErrBadPrecedence,
+		gotravelservice.ErrBadBreak,
+		gotravelservice.ErrBadException,
+		gotravelservice.ErrPrecedenceCycle,
+		gotravelservice.ErrPrecedenceImpossible,
 		gotravelservice.ErrBadMode,
 		gotravelservice.ErrBadTravelMode:
 		return http.StatusBadRequest
@@ -153,7 +266,8 @@ func errToStatus(err error) int {
 	switch err.(type) {
 	case
 		gotravelservice.ErrBadDescription,
-		gotravelservice.ErrDescriptionInaccurate:
+		gotravelservice.ErrDescriptionInaccurate,
+		gotravelservice.ErrRequiredMissed:
 		return http.StatusBadRequest
 	}
 	return http.StatusInternalServerError
@@ -0,0 +1,36 @@
+package gotraveltransport
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice"
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/planner"
+)
+
+func TestErrorEncoderRequiredMissed(t *testing.T) {
+	missed := []planner.Missed{
+		{Place: 1, Name: "Hydropolis", Reason: "place closed at that day"},
+		{Place: 3, Name: "Sky Tower", Reason: "place closes too early"},
+	}
+	w := httptest.NewRecorder()
+	errorEncoder(context.Background(), gotravelservice.ErrRequiredMissed{Missed: missed}, w)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	var response struct {
+		Err    string           `json:"err"`
+		Missed []planner.Missed `json:"missed"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Err == "" || !reflect.DeepEqual(response.Missed, missed) {
+		t.Errorf("response %+v, want error with missed places %+v", response, missed)
+	}
+}
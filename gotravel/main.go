@@ -12,12 +12,53 @@ import (
 	"github.com/go-kit/kit/log"
 	"github.com/radekwlsk/go-travel/gotravel/gotravelendpoint"
 	"github.com/radekwlsk/go-travel/gotravel/gotravelservice"
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/planner"
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/provider"
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/provider/cache"
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/provider/google"
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/provider/gtfs"
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/provider/nominatim"
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/provider/osrm"
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/provider/record"
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/provider/retry"
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/tenant"
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/trip"
+	"github.com/radekwlsk/go-travel/gotravel/gotravelservice/usage"
 	"github.com/radekwlsk/go-travel/gotravel/gotraveltransport"
 )
 
 func main() {
 	var (
-		httpAddr = flag.String("http-addr", ":8080", "HTTP port to listen")
+		httpAddr  = flag.String("http-addr", ":8080", "HTTP port to listen")
+		recordDir = flag.String("record", "", "directory to record provider responses to")
+		replayDir = flag.String("replay", "", "directory to replay recorded provider responses from, "+
+			"no requests are made to the provider")
+		osrmServers = flag.String("osrm", "", "comma separated mode=url list of OSRM servers used for "+
+			"travel matrices in given modes, e.g. driving=http://localhost:5000")
+		gtfsDir           = flag.String("gtfs", "", "directory with GTFS feed used for transit travel matrices")
+		matrixConcurrency = flag.Int("matrix-concurrency", planner.DefaultMatrixConcurrency,
+			"number of travel matrix requests of single trip fetched at once")
+		nominatimURL = flag.String("nominatim", "", "base URL of Nominatim server used to resolve places "+
+			"and fetch their details, e.g. https://nominatim.openstreetmap.org")
+		providerRate = flag.Float64("provider-rate", retry.DefaultOptions.Rate,
+			"provider calls per second allowed for single API key, 0 for no limit")
+		nominatimRate = flag.Float64("nominatim-rate", 1, "requests per second made to Nominatim server, "+
+			"public nominatim.openstreetmap.org allows 1, 0 for no limit")
+		osrmRate = flag.Float64("osrm-rate", retry.DefaultOptions.Rate,
+			"requests per second made to OSRM servers, 0 for no limit")
+		providerRetries = flag.Int("provider-retries", retry.DefaultOptions.MaxAttempts-1,
+			"number of retries of provider calls failed with temporary errors")
+		tenantsPath = flag.String("tenants", "", "JSON file with tenants and their API keys, "+
+			"requests are not authenticated if empty")
+		allowClientKeys = flag.Bool("allow-client-keys", false, "allow requests not authenticated as any "+
+			"tenant to use their own apiKey")
+		cachePath = flag.String("cache", "gotravel-cache.db", "file of persistent provider responses cache, "+
+			"empty to disable caching")
+		cachePlacesTTL  = flag.Duration("cache-places-ttl", cache.DefaultTTLs.Places, "time for which place IDs are cached")
+		cacheDetailsTTL = flag.Duration("cache-details-ttl", cache.DefaultTTLs.Details, "time for which place details are cached")
+		cacheMatrixTTL  = flag.Duration("cache-matrix-ttl", cache.DefaultTTLs.Matrix, "time for which travel matrices are cached")
+		exceptionsPath  = flag.String("exceptions", "", "JSON file with date-specific opening hours exceptions "+
+			"of places in regions")
 	)
 	flag.Parse()
 
@@ -31,15 +72,103 @@ func main() {
 	logger.Log("msg", "gotravel service started")
 	defer logger.Log("msg", "finished")
 
+	if *recordDir != "" && *replayDir != "" {
+		logger.Log("exit", "-record and -replay can't be used together")
+		os.Exit(1)
+	}
+
+	var config gotravelservice.Config
+	{
+		config.MatrixConcurrency = *matrixConcurrency
+		if *tenantsPath != "" {
+			tenants, err := tenant.Load(*tenantsPath)
+			if err != nil {
+				logger.Log("exit", err)
+				os.Exit(1)
+			}
+			config.Tenants = tenants
+			config.AllowClientKeys = *allowClientKeys
+			logger.Log("msg", "authenticating tenants", "path", *tenantsPath, "allowClientKeys", *allowClientKeys)
+		}
+		if *exceptionsPath != "" {
+			exceptions, err := trip.LoadExceptions(*exceptionsPath)
+			if err != nil {
+				logger.Log("exit", err)
+				os.Exit(1)
+			}
+			config.Exceptions = exceptions
+			logger.Log("msg", "using opening hours exceptions", "path", *exceptionsPath, "count", len(exceptions))
+		}
+		options := retry.DefaultOptions
+		options.Rate = *providerRate
+		options.MaxAttempts = *providerRetries + 1
+		// only calls made to Google Maps count against quota of the API key
+		config.Providers = retry.NewLimitedFactory(usage.NewFactory(google.NewFactory(http.DefaultClient)), options)
+		backends := provider.Backends{Places: google.Backend, Matrix: google.Backend}
+		if *nominatimURL != "" {
+			client := &http.Client{Transport: retry.NewTransport(nil, retry.Options{Rate: *nominatimRate, Burst: 1})}
+			config.Providers = nominatim.NewFactory(config.Providers, *nominatimURL, client)
+			backends.Places = "nominatim " + *nominatimURL
+			logger.Log("msg", "using Nominatim places", "url", *nominatimURL)
+		}
+		if servers, err := osrm.ParseServers(*osrmServers); err != nil {
+			logger.Log("exit", err)
+			os.Exit(1)
+		} else if len(servers) > 0 {
+			client := &http.Client{Transport: retry.NewTransport(nil, retry.Options{Rate: *osrmRate, Burst: 1})}
+			config.Providers = osrm.NewFactory(config.Providers, servers, client)
+			for mode, server := range servers {
+				backends.Serve(mode, "osrm "+server)
+			}
+			logger.Log("msg", "using OSRM travel matrices", "servers", *osrmServers)
+		}
+		if *gtfsDir != "" {
+			feed, err := gtfs.Load(*gtfsDir)
+			if err != nil {
+				logger.Log("exit", err)
+				os.Exit(1)
+			}
+			config.Providers = gtfs.NewFactory(config.Providers, feed)
+			backends.Serve(provider.TravelModeTransit, "gtfs "+*gtfsDir)
+			logger.Log("msg", "using GTFS transit travel matrices", "dir", *gtfsDir)
+		}
+		config.Providers = retry.NewFactory(config.Providers, options, log.With(logger, "component", "provider"))
+		config.Backends = backends
+		if *cachePath != "" && *replayDir == "" {
+			store, err := cache.Open(*cachePath, cache.TTLs{
+				Places:  *cachePlacesTTL,
+				Details: *cacheDetailsTTL,
+				Matrix:  *cacheMatrixTTL,
+			})
+			if err != nil {
+				logger.Log("exit", err)
+				os.Exit(1)
+			}
+			defer store.Close()
+			config.Providers = cache.NewFactory(config.Providers, store, backends)
+			logger.Log("msg", "caching provider responses", "path", *cachePath)
+		}
+		if *recordDir != "" {
+			config.Providers = record.NewRecordingFactory(config.Providers, *recordDir)
+			logger.Log("msg", "recording provider responses", "dir", *recordDir)
+		}
+		if *replayDir != "" {
+			config.Providers = record.NewReplayFactory(*replayDir)
+			config.Backends = provider.Backends{Places: "replay", Matrix: "replay"}
+			config.AllowPastTrips = true
+			logger.Log("msg", "replaying provider responses", "dir", *replayDir)
+		}
+	}
+
 	var (
-		service     = gotravelservice.New(logger)
+		service     = gotravelservice.New(logger, config)
 		endpoints   = gotravelendpoint.New(service, logger)
 		httpHandler = gotraveltransport.MakeHTTPHandler(endpoints, logger)
 	)
 
 	errs := make(chan error)
 	go func() {
-		c := make(chan os.Signal)
+		c := make(chan os.Signal, 1)
 		signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
 		errs <- fmt.Errorf("%s", <-c)
 	}()